@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// addedEvent is how `vanitycal add` and the serve API write an event,
+// omitting unset keys.
+type addedEvent struct {
+	Title           string                 `toml:"title"`
+	Date            string                 `toml:"date,omitempty"`
+	MonthDay        string                 `toml:"month_day,omitempty"`
+	Description     string                 `toml:"description,omitempty"`
+	Tags            []string               `toml:"tags,omitempty"`
+	Emoji           *string                `toml:"emoji,omitempty"`
+	SummaryTemplate string                 `toml:"summary_template,omitempty"`
+	Alarms          []string               `toml:"alarms,omitempty"`
+	Anniversaries   *vanitycal.Anniversary `toml:"anniversaries,omitempty"`
+}
+
+// runAdd implements `vanitycal add`: it appends an event instantiated from a
+// template to a TOML config.
+func runAdd(args []string) int {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	path := fs.String("config", "", "Path of the TOML config to append the event to")
+	templateName := fs.String("template", "", "Template of the event: "+strings.Join(vanitycal.TemplateNames(), ", "))
+	title := fs.String("title", "", "Title of the event")
+	date := fs.String("date", "", "Date of the event (YYYY-MM-DD)")
+	description := fs.String("description", "", "Description of the event (default: from the template)")
+	dryRun := fs.Bool("dry-run", false, "Print the event instead of appending it")
+	list := fs.Bool("list", false, "List the available templates")
+	_ = fs.Parse(args)
+
+	if *list {
+		for _, name := range vanitycal.TemplateNames() {
+			template, _ := vanitycal.LookupTemplate(name)
+			fmt.Printf("%-16s%s\n", name, template.Description)
+		}
+		return exitOK
+	}
+
+	template, found := vanitycal.LookupTemplate(*templateName)
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown template %q, expected one of: %s\n", *templateName, strings.Join(vanitycal.TemplateNames(), ", "))
+		return exitUsage
+	}
+	if *title == "" {
+		fmt.Fprintln(os.Stderr, "add requires a title")
+		return exitUsage
+	}
+	if _, err := time.Parse("2006-01-02", *date); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid date %q, expected YYYY-MM-DD\n", *date)
+		return exitUsage
+	}
+	if !*dryRun && *path == "" {
+		fmt.Fprintln(os.Stderr, "add requires a config file, or -dry-run")
+		return exitUsage
+	}
+
+	event := template.Event(*title, *date)
+	if *description != "" {
+		event.Description = *description
+	}
+	added := addedEvent{
+		Title:           event.Title,
+		Date:            event.Date,
+		Description:     event.Description,
+		Emoji:           event.Emoji,
+		SummaryTemplate: event.SummaryTemplate,
+		Alarms:          event.Alarms,
+		Anniversaries:   event.Anniversaries,
+	}
+	if *dryRun {
+		encoded, err := encodeAddedEvent(added)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitOutputError
+		}
+		fmt.Print(string(encoded))
+		return exitOK
+	}
+	if err := checkEditableConfig(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "%v, use -dry-run to print the event\n", err)
+		return exitUsage
+	}
+
+	if err := appendEvent(*path, added); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	fmt.Printf("%s: added %q from template %s\n", *path, event.Title, template.Name)
+	return exitOK
+}
+
+// encodeAddedEvent returns event as an [[events]] TOML table.
+func encodeAddedEvent(event addedEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	err := encoder.Encode(struct {
+		Events []addedEvent `toml:"events"`
+	}{[]addedEvent{event}})
+	return buf.Bytes(), err
+}
+
+// checkEditableConfig tells why events can't be appended to the config at
+// path, if so: only local TOML files are editable, not URLs, stdin or
+// directories.
+func checkEditableConfig(path string) error {
+	if path == "-" || vanitycal.IsURL(path) {
+		return fmt.Errorf("only local files can be edited")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return fmt.Errorf("only TOML configs can be edited")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file, only config files can be edited", path)
+	}
+	return nil
+}
+
+// appendedEvent returns the TOML config at path with event appended, after
+// a blank line and keeping the rest of the file untouched.
+func appendedEvent(path string, event addedEvent) ([]byte, error) {
+	encoded, err := encodeAddedEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		data = append(bytes.TrimRight(data, "\n"), '\n', '\n')
+	}
+	return append(data, encoded...), nil
+}
+
+// appendEvent appends event to the TOML config at path, see appendedEvent.
+func appendEvent(path string, event addedEvent) error {
+	data, err := appendedEvent(path, event)
+	if err != nil {
+		return err
+	}
+	tmp, err := writeTempFile(path, data)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeTempFile writes data to a new file next to path, with the same
+// extension and permissions, to be renamed over path once complete so that
+// readers never see a half-written config.
+func writeTempFile(path string, data []byte) (string, error) {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	base := filepath.Base(path)
+	file, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(base, filepath.Ext(base))+".*"+filepath.Ext(base))
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// auditLog is the append-only JSONL log of the changes made to the configs
+// of a directory through the events API and `vanitycal rollback`.
+const auditLog = ".vanitycal-audit.jsonl"
+
+// Actions recorded in the audit log.
+const (
+	auditAddEvent = "add-event"
+	auditRollback = "rollback"
+)
+
+// auditEntry is a line of the audit log: who changed which calendar, when
+// and how, and the version of the config it resulted in.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Calendar string    `json:"calendar"`
+	Actor    string    `json:"actor"`
+	Action   string    `json:"action"`
+	Detail   string    `json:"detail,omitempty"`
+	Version  int       `json:"version,omitempty"`
+}
+
+func auditLogPath(path string) string {
+	return filepath.Join(filepath.Dir(path), auditLog)
+}
+
+// appendAudit records entry in the audit log of the config at path, dated
+// now when its time isn't set.
+func appendAudit(path string, entry auditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC().Truncate(time.Second)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(auditLogPath(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %w", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %w", err)
+	}
+	return nil
+}
+
+// readAudit returns the entries of the audit log of the config at path
+// about its calendar, oldest first.
+func readAudit(path string) ([]auditEntry, error) {
+	file, err := os.Open(auditLogPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	calendar := calendarName(path)
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", auditLogPath(path), line, err)
+		}
+		if entry.Calendar == calendar {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// calendarName is the name of the config at path in the events API.
+func calendarName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// localActor is who changes configs from the command line.
+func localActor() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// runAudit implements `vanitycal audit`: it prints who changed a config
+// through the events API or `vanitycal rollback`, and when.
+func runAudit(args []string) int {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	path := fs.String("config", "", "Path of the served config file")
+	_ = fs.Parse(args)
+
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "audit requires a config file")
+		fs.Usage()
+		return exitUsage
+	}
+	entries, err := readAudit(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	for _, entry := range entries {
+		fmt.Printf("%s\t%s\t%s\t%q\tversion %d\n", entry.Time.Format(time.RFC3339), entry.Actor, entry.Action, entry.Detail, entry.Version)
+	}
+	return exitOK
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier, err := newOIDCVerifier(provider.URL, "vanitycal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "team.toml")
+	if err := os.WriteFile(path, []byte("title = \"Team\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// another calendar of the same directory shares the log.
+	other := filepath.Join(dir, "family.toml")
+	if err := os.WriteFile(other, []byte("title = \"Family\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	api := bearerAuth{token: "static-token", verifier: verifier}.middleware(&eventsAPI{configs: &configFlags{paths: configPaths{path, other}}})
+	oidcToken := sign(t, provider.key, "k1", map[string]interface{}{"iss": provider.URL, "aud": "vanitycal", "exp": time.Now().Add(time.Hour).Unix(), "sub": "42", "email": "alice@example.com"})
+	for _, post := range []struct{ calendar, token, title string }{
+		{"team", "static-token", "Launch"},
+		{"family", oidcToken, "Wedding"},
+		{"team", oidcToken, "Retro"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/calendars/"+post.calendar+"/events", strings.NewReader(`{"title": "`+post.title+`", "date": "2020-01-02"}`))
+		r.Header.Set("Authorization", "Bearer "+post.token)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, r)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+		}
+	}
+	if code := runRollback([]string{"-config", path, "-to", "2"}); code != exitOK {
+		t.Fatalf("rollback exited with %d", code)
+	}
+
+	entries, err := readAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []auditEntry{
+		{Calendar: "team", Actor: staticTokenIdentity, Action: auditAddEvent, Detail: "Launch", Version: 2},
+		{Calendar: "team", Actor: "alice@example.com", Action: auditAddEvent, Detail: "Retro", Version: 3},
+		{Calendar: "team", Actor: localActor(), Action: auditRollback, Detail: "to version 2", Version: 4},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Time.IsZero() {
+			t.Errorf("entry %d has no time", i)
+		}
+		entry.Time = time.Time{}
+		if entry != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
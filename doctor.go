@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// doctorReport collects the findings of `vanitycal doctor`.
+type doctorReport struct {
+	warnings int
+	failures int
+}
+
+func (r *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Printf("OK    "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(format string, args ...interface{}) {
+	r.warnings++
+	fmt.Printf("WARN  "+format+"\n", args...)
+}
+
+func (r *doctorReport) fail(format string, args ...interface{}) {
+	r.failures++
+	fmt.Printf("FAIL  "+format+"\n", args...)
+}
+
+// runDoctor implements `vanitycal doctor`: it fetches a published feed,
+// validates it, optionally compares it with a local generation, and reports
+// what popular clients are likely to do with it.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	feedURL := fs.String("url", "", "URL of the published feed (webcal://, http:// or https://)")
+	var configs configFlags
+	configs.register(fs)
+	_ = fs.Parse(args)
+
+	if *feedURL == "" {
+		fmt.Fprintln(os.Stderr, "doctor requires a feed url")
+		fs.Usage()
+		return exitUsage
+	}
+
+	report := &doctorReport{}
+	fetchURL := *feedURL
+	if strings.HasPrefix(fetchURL, "webcal://") {
+		fetchURL = "https://" + strings.TrimPrefix(fetchURL, "webcal://")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(fetchURL)
+	if err != nil {
+		report.fail("fetch %s: %v", fetchURL, err)
+		return exitOutputError
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		report.fail("read %s: %v", fetchURL, err)
+		return exitOutputError
+	}
+	if resp.StatusCode != http.StatusOK {
+		report.fail("fetch %s: %s", fetchURL, resp.Status)
+		return exitOutputError
+	}
+	report.ok("fetched %s (%d bytes)", fetchURL, len(body))
+
+	checkHeaders(report, resp.Header)
+	cal := checkFeed(report, body)
+
+	// only compare with a local generation when a config is given.
+	if cal != nil && len(configs.paths) > 0 {
+		config, _, err := configs.load()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfigError
+		}
+		events, err := vanitycal.GenerateEvents(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitValidationError
+		}
+		compareWithLocal(report, cal, events)
+	}
+
+	fmt.Printf("\n%d failure(s), %d warning(s)\n", report.failures, report.warnings)
+	switch {
+	case report.failures > 0:
+		return exitValidationError
+	case report.warnings > 0:
+		return exitGenerationWarning
+	}
+	return exitOK
+}
+
+func checkHeaders(report *doctorReport, header http.Header) {
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/calendar") {
+		report.ok("Content-Type is %s", contentType)
+	} else {
+		report.warn("Content-Type is %q, some clients (Outlook, Google) refuse feeds not served as text/calendar", contentType)
+	}
+	if contentType != "" && !strings.Contains(strings.ToLower(contentType), "charset=utf-8") {
+		report.warn("Content-Type has no charset=utf-8, emoji and accents may be garbled")
+	}
+
+	if header.Get("ETag") == "" && header.Get("Last-Modified") == "" {
+		report.warn("no ETag nor Last-Modified header, clients can't do conditional requests")
+	} else {
+		report.ok("conditional requests possible (ETag: %q, Last-Modified: %q)", header.Get("ETag"), header.Get("Last-Modified"))
+	}
+	if cacheControl := header.Get("Cache-Control"); cacheControl != "" {
+		report.ok("Cache-Control: %s", cacheControl)
+	} else {
+		report.warn("no Cache-Control header, intermediate caches may serve stale feeds")
+	}
+}
+
+func checkFeed(report *doctorReport, body []byte) *ical.Calendar {
+	if bytes.HasPrefix(body, []byte("\xef\xbb\xbf")) {
+		report.warn("feed starts with a UTF-8 BOM, which some clients reject")
+		body = body[3:]
+	}
+	if !bytes.Contains(body, []byte("\r\n")) {
+		report.warn("feed uses LF line endings, RFC 5545 requires CRLF")
+	}
+
+	cal, err := ical.ParseCalendar(bytes.NewReader(body))
+	if err != nil {
+		report.fail("feed can't be parsed: %v", err)
+		return nil
+	}
+	report.ok("feed parses as iCalendar")
+
+	hasProperty := func(property ical.Property) bool {
+		for _, p := range cal.CalendarProperties {
+			if p.IANAToken == string(property) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, property := range []ical.Property{ical.PropertyVersion, ical.PropertyProductId} {
+		if !hasProperty(property) {
+			report.fail("calendar has no %s", property)
+		}
+	}
+	if !hasProperty(ical.PropertyRefreshInterval) && !hasProperty(ical.PropertyXPublishedTTL) {
+		report.warn("no REFRESH-INTERVAL nor X-PUBLISHED-TTL, Apple Calendar and Outlook fall back to their own (often weekly) refresh")
+	}
+
+	events := cal.Events()
+	report.ok("%d event(s)", len(events))
+	uids := map[string]int{}
+	missingStamp := 0
+	for _, event := range events {
+		uids[event.Id()]++
+		if event.GetProperty(ical.ComponentPropertyDtStart) == nil {
+			report.fail("event %q has no DTSTART and will be dropped by every client", event.Id())
+		}
+		if event.GetProperty(ical.ComponentPropertyDtstamp) == nil {
+			missingStamp++
+		}
+	}
+	for uid, count := range uids {
+		switch {
+		case uid == "":
+			report.fail("%d event(s) without UID", count)
+		case count > 1:
+			report.warn("UID %s is used %d times, Google Calendar and Apple Calendar keep only one of them", uid, count)
+		}
+	}
+	if missingStamp > 0 {
+		report.warn("%d event(s) without DTSTAMP, strict clients (Outlook) may reject them", missingStamp)
+	}
+	return cal
+}
+
+func compareWithLocal(report *doctorReport, cal *ical.Calendar, events []vanitycal.GeneratedEvent) {
+	published := map[string]bool{}
+	for _, event := range cal.Events() {
+		if summary := event.GetProperty(ical.ComponentPropertySummary); summary != nil {
+			published[event.Id()+"\x00"+summary.Value] = true
+		}
+	}
+	local := map[string]bool{}
+	missing := 0
+	for _, event := range events {
+		// the parsed feed has unescaped values.
+		key := event.UID + "\x00" + event.Summary
+		local[key] = true
+		if !published[key] {
+			missing++
+		}
+	}
+	extra := 0
+	for key := range published {
+		if !local[key] {
+			extra++
+		}
+	}
+	if missing == 0 && extra == 0 {
+		report.ok("feed matches the local generation")
+		return
+	}
+	report.warn("feed differs from the local generation: %d event(s) missing, %d unexpected; republish the feed", missing, extra)
+}
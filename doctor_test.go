@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ical "github.com/arran4/golang-ical"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+func TestCompareWithLocalEscapedSummary(t *testing.T) {
+	config := vanitycal.Config{Events: []vanitycal.Event{{
+		Title: `Smith, John; \o/`,
+		Date:  "2020-01-02",
+	}}}
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := vanitycal.RenderICal(config, events, &buf); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := ical.ParseCalendar(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := &doctorReport{}
+	compareWithLocal(report, cal, events)
+	if report.warnings != 0 || report.failures != 0 {
+		t.Errorf("got %d warning(s) and %d failure(s) against our own feed, want none", report.warnings, report.failures)
+	}
+}
+
+func TestDoctorServedFeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := "[[events]]\ntitle = \"Us\"\ndate = \"2020-01-02\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs := &configFlags{paths: configPaths{path}}
+	rec := httptest.NewRecorder()
+	serveCalendar(rec, httptest.NewRequest(http.MethodGet, "/", nil), configs, nil)
+
+	report := &doctorReport{}
+	checkHeaders(report, rec.Header())
+	if cal := checkFeed(report, rec.Body.Bytes()); cal == nil {
+		t.Fatal("served feed doesn't parse")
+	}
+	if report.failures != 0 {
+		t.Errorf("got %d failure(s) against our own feed, want none", report.failures)
+	}
+	// no refresh_interval in the config.
+	if report.warnings != 1 {
+		t.Errorf("got %d warning(s) against our own feed, want 1", report.warnings)
+	}
+
+	rec2 := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	serveCalendar(rec2, req, configs, nil)
+	if rec2.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %d, want %d", rec2.Code, http.StatusNotModified)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// configFlags are the config-related flags shared by every subcommand.
+type configFlags struct {
+	paths configPaths
+	opts  vanitycal.LoadOptions
+	now   string
+}
+
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.Var(&c.paths, "config", "Path, directory or HTTP(S) URL of a config file, repeatable (default '-' for stdin)")
+	fs.StringVar(&c.opts.Format, "format", "", "Config format: 'toml', 'yaml' or 'json' (default: from the file extension, toml for stdin)")
+	fs.BoolVar(&c.opts.Strict, "strict", false, "Reject unknown config keys")
+	fs.StringVar(&c.opts.OnConflict, "on-conflict", vanitycal.OnConflictOverride, "When several configs set the same setting: 'override' (last one wins) or 'error'")
+	fs.BoolVar(&c.opts.AllowPartial, "allow-partial", false, "Skip config files that fail to load instead of aborting")
+	fs.StringVar(&c.now, "now", "", "Generate as if the current time were this date (YYYY-MM-DD) or RFC 3339 timestamp, for reproducible output")
+}
+
+// load loads and merges the configured files, reading stdin if none is set.
+func (c *configFlags) load() (vanitycal.Config, []error, error) {
+	paths := c.paths
+	if len(paths) == 0 {
+		paths = configPaths{"-"}
+	}
+	var now time.Time
+	if c.now != "" {
+		var err error
+		if now, err = time.Parse("2006-01-02", c.now); err != nil {
+			if now, err = time.Parse(time.RFC3339, c.now); err != nil {
+				return vanitycal.Config{}, nil, fmt.Errorf("Invalid now %q, expected YYYY-MM-DD or an RFC 3339 timestamp", c.now)
+			}
+		}
+	}
+
+	config, failures, err := vanitycal.LoadConfigs(paths, c.opts)
+	if err == nil && c.now != "" {
+		config.Clock = func() time.Time { return now }
+	}
+	return config, failures, err
+}
+
+// stringList is a repeatable flag, each value possibly comma-separated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// configPaths is a repeatable -config flag.
+type configPaths []string
+
+func (p *configPaths) String() string { return strings.Join(*p, ",") }
+
+func (p *configPaths) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
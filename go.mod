@@ -7,4 +7,7 @@ toolchain go1.22.1
 require (
 	github.com/BurntSushi/toml v1.4.0
 	github.com/arran4/golang-ical v0.3.0
+	golang.org/x/text v0.14.0
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,155 +1,302 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/BurntSushi/toml"
-	ical "github.com/arran4/golang-ical"
+	"moul.io/vanitycal/pkg/vanitycal"
 )
 
-type Event struct {
-	Date        string `toml:"date"`
-	Title       string `toml:"title"`
-	Description string `toml:"description"`
+// Exit codes, so that scripts can branch on what went wrong.
+const (
+	exitOK = 0
+	// exitUsage is also what the flag package uses for invalid flags.
+	exitUsage             = 2
+	exitConfigError       = 3
+	exitValidationError   = 4
+	exitGenerationWarning = 5
+	exitOutputError       = 6
+	// exitPartialSourceFailure is used when some of several inputs couldn't
+	// be loaded but an output was still produced.
+	exitPartialSourceFailure = 7
+)
+
+type options struct {
+	config       configFlags
+	outputFile   string
+	lineEnding   string
+	bom          bool
+	outputFormat string
+	splitBy      string
+	resultJSON   string
+	stats        string
+	statePath    string
+	from         string
+	until        string
+	only         stringList
+	exclude      stringList
 }
 
-type Config struct {
-	Events []Event `toml:"events"`
+// runResult is the machine-readable report written by --result-json.
+type runResult struct {
+	Status   string   `json:"status"`
+	ExitCode int      `json:"exit_code"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Events   int      `json:"events"`
+	Output   string   `json:"output,omitempty"`
 }
 
 func main() {
-	configFile := flag.String("config", "-", "Path to the config file (use '-' for stdin)")
-	outputFile := flag.String("output", "-", "Path to the output file (use '-' for stdout)")
+	if len(os.Args) > 1 {
+		if subcommand, found := subcommands[os.Args[1]]; found {
+			os.Exit(subcommand(os.Args[2:]))
+		}
+	}
+
+	var opts options
+	opts.config.register(flag.CommandLine)
+	flag.StringVar(&opts.outputFile, "output", "-", "Path to the output file (use '-' for stdout)")
+	flag.StringVar(&opts.lineEnding, "line-ending", "crlf", "Line endings of the output: 'crlf' (RFC 5545) or 'lf'")
+	flag.BoolVar(&opts.bom, "bom", false, "Prefix the output with a UTF-8 byte order mark")
+	flag.StringVar(&opts.outputFormat, "output-format", "ics", "Output format: 'ics' (iCalendar 2.0), 'vcs' (legacy vCalendar 1.0) or 'pb' (Protocol Buffers, see pkg/vanitycal/vanitycal.proto)")
+	flag.StringVar(&opts.splitBy, "split-by", "", "Split the output in one file per 'year', plus one for recurring events, written with an index in the output directory")
+	flag.StringVar(&opts.resultJSON, "result-json", "", "Write a machine-readable run report to this file")
+	flag.StringVar(&opts.statePath, "state", "", "State file tracking published events, to bump SEQUENCE of changed events and keep DTSTAMP of unchanged ones")
+	flag.StringVar(&opts.stats, "stats", "", "Print a summary of the generated events to stderr: 'text' or 'json'")
+	flag.StringVar(&opts.from, "from", "", "Only write events from this day: YYYY-MM-DD, 'today' or relative to today like '-6m'")
+	flag.StringVar(&opts.until, "until", "", "Only write events until this day: YYYY-MM-DD, 'today' or relative to today like '+2y'")
+	flag.Var(&opts.only, "only-category", "Only write events with this category (repeatable, or comma-separated)")
+	flag.Var(&opts.exclude, "exclude-category", "Don't write events with this category (repeatable, or comma-separated)")
 	flag.Parse()
 
-	if *configFile == "" || *outputFile == "" {
-		fmt.Println("Both config and output flags are required")
-		flag.Usage()
-		return
+	result := run(opts)
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	if result.Error != "" {
+		fmt.Fprintln(os.Stderr, result.Error)
+		if result.ExitCode == exitUsage {
+			flag.Usage()
+		}
+	}
+
+	if opts.resultJSON != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err == nil {
+			err = os.WriteFile(opts.resultJSON, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("Error writing result file: %w", err))
+			if result.ExitCode == exitOK {
+				result.ExitCode = exitOutputError
+			}
+		}
 	}
+	os.Exit(result.ExitCode)
+}
 
-	var config Config
-	var err error
+func run(opts options) *runResult {
+	result := &runResult{Status: "ok", Output: opts.outputFile}
+	fail := func(code int, err error) *runResult {
+		result.Status = "error"
+		result.ExitCode = code
+		result.Error = err.Error()
+		return result
+	}
 
-	if *configFile == "-" {
-		_, err = toml.NewDecoder(os.Stdin).Decode(&config)
-	} else {
-		_, err = toml.DecodeFile(*configFile, &config)
+	if opts.splitBy != "" && opts.splitBy != "year" {
+		return fail(exitUsage, fmt.Errorf("Invalid split-by, expected 'year'"))
+	}
+	if opts.splitBy != "" && opts.outputFile == "-" {
+		return fail(exitUsage, fmt.Errorf("split-by requires an output directory"))
 	}
 
+	render, found := renderers[opts.outputFormat]
+	if !found {
+		return fail(exitUsage, fmt.Errorf("Invalid output-format, expected 'ics', 'vcs' or 'pb'"))
+	}
+	if opts.outputFormat == "pb" && opts.bom {
+		return fail(exitUsage, fmt.Errorf("bom can't be used with the binary pb output-format"))
+	}
+
+	if opts.stats != "" && opts.stats != "text" && opts.stats != "json" {
+		return fail(exitUsage, fmt.Errorf("Invalid stats, expected 'text' or 'json'"))
+	}
+
+	if opts.lineEnding != "crlf" && opts.lineEnding != "lf" {
+		return fail(exitUsage, fmt.Errorf("Invalid line-ending, expected 'crlf' or 'lf'"))
+	}
+
+	if (len(opts.config.paths) > 0 && opts.config.paths[0] == "") || opts.outputFile == "" {
+		return fail(exitUsage, fmt.Errorf("Both config and output flags are required"))
+	}
+
+	config, failures, err := opts.config.load()
 	if err != nil {
-		panic(fmt.Errorf("Error reading config file: %w", err))
+		return fail(exitConfigError, err)
+	}
+	for _, failure := range failures {
+		result.Warnings = append(result.Warnings, failure.Error())
+	}
+	if config.Range.From, err = parseDay(opts.from, config.Today()); err != nil {
+		return fail(exitUsage, fmt.Errorf("Invalid from: %w", err))
+	}
+	if config.Range.Until, err = parseDay(opts.until, config.Today()); err != nil {
+		return fail(exitUsage, fmt.Errorf("Invalid until: %w", err))
 	}
 
-	var output io.Writer
-	if *outputFile == "-" {
-		output = os.Stdout
+	config.OnlyCategories, config.ExcludeCategories = opts.only, opts.exclude
+
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		return fail(exitValidationError, fmt.Errorf("Error generating events: %w", err))
+	}
+	result.Events = len(events)
+	result.Warnings = append(result.Warnings, vanitycal.LintHorizon(config)...)
+	result.Warnings = append(result.Warnings, vanitycal.LintEvents(events)...)
+
+	var state *vanitycal.State
+	if opts.statePath != "" {
+		state, err = vanitycal.LoadState(opts.statePath)
+		if err != nil {
+			return fail(exitConfigError, err)
+		}
+		state.Apply(events, config.Now())
+	}
+
+	encode := func(data []byte) []byte {
+		if opts.outputFormat == "pb" {
+			return data
+		}
+		return encodeOutput(data, opts.lineEnding, opts.bom)
+	}
+	if opts.splitBy == "year" {
+		err = writeSplitByYear(opts.outputFile, opts.outputFormat, config, events, encode)
+		if err != nil {
+			return fail(exitOutputError, fmt.Errorf("Error writing split output: %w", err))
+		}
 	} else {
-		file, err := os.Create(*outputFile)
+		var buf bytes.Buffer
+		err = render(config, events, &buf)
 		if err != nil {
-			panic(fmt.Errorf("Error creating output file: %w", err))
+			return fail(exitValidationError, fmt.Errorf("Error generating %s file: %w", opts.outputFormat, err))
+		}
+		if err := writeOutput(opts.outputFile, encode(buf.Bytes())); err != nil {
+			return fail(exitOutputError, err)
 		}
-		defer file.Close()
-		output = file
 	}
 
-	err = generateICal(config, output)
-	if err != nil {
-		panic(fmt.Errorf("Error generating ics file: %w", err))
+	// only record the state once the calendar is published.
+	if state != nil {
+		if err := state.Save(opts.statePath); err != nil {
+			return fail(exitOutputError, err)
+		}
+	}
+
+	if opts.stats != "" {
+		if err := writeStats(os.Stderr, computeStats(config, events), opts.stats); err != nil {
+			return fail(exitOutputError, fmt.Errorf("Error writing stats: %w", err))
+		}
+	}
+
+	switch {
+	case len(failures) > 0:
+		result.Status = "warning"
+		result.ExitCode = exitPartialSourceFailure
+	case len(result.Warnings) > 0:
+		result.Status = "warning"
+		result.ExitCode = exitGenerationWarning
 	}
+	return result
 }
 
-func generateICal(config Config, output io.Writer) error {
-	cal := ical.NewCalendar()
-	cal.SetMethod(ical.MethodPublish)
-	cal.SetName("VanityCal 💚")
-	cal.SetDescription("")
-	cal.SetTimezoneId("Europe/Paris")
-	cal.SetTzid("Europe/Paris")
-	cal.SetCalscale("GREGORIAN")
-	cal.SetLastModified(time.Now()) // XXX: take last modification date of this binary AND the input.
-
-	for _, event := range config.Events {
-		date, err := time.Parse("2006-01-02", event.Date)
+// parseDay parses a day given as YYYY-MM-DD, "today", or relative to today
+// in days, weeks, months or years, e.g. "+90d", "-6m" or "+2y". It returns
+// the zero time for an empty value.
+func parseDay(value string, today time.Time) (time.Time, error) {
+	switch {
+	case value == "":
+		return time.Time{}, nil
+	case value == "today":
+		return today, nil
+	case strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-"):
+		n, err := strconv.Atoi(value[:len(value)-1])
 		if err != nil {
-			return fmt.Errorf("Error parsing date: %w", err)
+			break
 		}
-		anniversaries := getAnniversaries(date)
-		for _, anniv := range anniversaries {
-			duration := getDuration(date, anniv)
-			uuid := fmt.Sprintf("vanitycal-%s", anniv.Format("20060102"))
-			icalEvent := cal.AddEvent(uuid)
-			summary := fmt.Sprintf("%s - %s 💚", event.Title, duration)
-			icalEvent.SetSummary(summary)
-			if event.Description != "" {
-				icalEvent.SetDescription(event.Description)
-			}
-
-			// fullday
-			icalEvent.SetProperty(ical.ComponentPropertyDtStart, anniv.UTC().Format("20060102"), ical.WithValue("DATE"))
+		switch value[len(value)-1] {
+		case 'd':
+			return today.AddDate(0, 0, n), nil
+		case 'w':
+			return today.AddDate(0, 0, 7*n), nil
+		case 'm':
+			return today.AddDate(0, n, 0), nil
+		case 'y':
+			return today.AddDate(n, 0, 0), nil
+		}
+	default:
+		if day, err := time.Parse("2006-01-02", value); err == nil {
+			return day, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q, expected YYYY-MM-DD, 'today' or an offset like '+2y', '-6m', '+12w' or '+90d'", value)
+}
 
-			// XXX: specific hours
-			//icalEvent.SetStartAt(anniv)
-			//icalEvent.SetEndAt(anniv.Add(24 * time.Hour))
+func writeOutput(path string, data []byte) error {
+	var output io.Writer
+	if path == "-" {
+		output = os.Stdout
+	} else {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Error creating output file: %w", err)
 		}
+		defer file.Close()
+		output = file
 	}
 
-	_, err := output.Write([]byte(cal.Serialize()))
-	return err
+	if _, err := output.Write(data); err != nil {
+		return fmt.Errorf("Error writing output: %w", err)
+	}
+	return nil
 }
 
-func getAnniversaries(date time.Time) []time.Time {
-	return []time.Time{
-		date,                       // d day
-		date.AddDate(1, 0, 0),      // 1 year
-		date.AddDate(2, 0, 0),      // 2 years
-		date.AddDate(3, 0, 0),      // 3 years
-		date.AddDate(4, 0, 0),      // 4 years
-		date.AddDate(5, 0, 0),      // 5 years
-		date.AddDate(6, 0, 0),      // 6 years
-		date.AddDate(7, 0, 0),      // 7 years
-		date.AddDate(8, 0, 0),      // 8 years
-		date.AddDate(9, 0, 0),      // 9 years
-		date.AddDate(10, 0, 0),     // 10 years
-		date.AddDate(15, 0, 0),     // 15 years
-		date.AddDate(20, 0, 0),     // 20 years
-		date.AddDate(25, 0, 0),     // 25 years
-		date.AddDate(30, 0, 0),     // 30 years
-		date.AddDate(35, 0, 0),     // 35 years
-		date.AddDate(40, 0, 0),     // 40 years
-		date.AddDate(45, 0, 0),     // 45 years
-		date.AddDate(50, 0, 0),     // 50 years
-		date.AddDate(0, 0, 7),      // 7 days
-		date.AddDate(0, 0, 100),    // 100 days
-		date.AddDate(0, 0, 1_000),  // 1 000 days
-		date.AddDate(0, 0, 10_000), // 10 000 days
-		date.AddDate(0, 1, 0),      // 1 month
-		date.AddDate(0, 2, 0),      // 2 month
-		date.AddDate(0, 3, 0),      // 3 month
-		date.AddDate(0, 6, 0),      // 6 months
-		date.AddDate(0, 9, 0),      // 9 months
-	}
+var subcommands = map[string]func(args []string) int{
+	"serve":    runServe,
+	"add":      runAdd,
+	"audit":    runAudit,
+	"doctor":   runDoctor,
+	"migrate":  runMigrate,
+	"next":     runNext,
+	"preview":  runPreview,
+	"review":   runReview,
+	"rollback": runRollback,
+	"schema":   runSchema,
+	"validate": runValidate,
 }
 
-func getDuration(start, end time.Time) string {
-	years := end.Year() - start.Year()
-	months := int(end.Sub(start).Hours() / (24 * 30))
-	days := int(end.Sub(start).Hours() / 24)
+var renderers = map[string]func(vanitycal.Config, []vanitycal.GeneratedEvent, io.Writer) error{
+	"ics": vanitycal.RenderICal,
+	"vcs": vanitycal.RenderVCS,
+	"pb":  vanitycal.RenderPB,
+}
 
-	if end == start {
-		return "D-DAY"
+// encodeOutput applies the requested line endings and optional UTF-8 BOM to
+// the serialized calendar, which always uses CRLF.
+func encodeOutput(data []byte, lineEnding string, bom bool) []byte {
+	if lineEnding == "lf" {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
 	}
-	if years > 0 && end.AddDate(-years, 0, 0).Equal(start) {
-		return fmt.Sprintf("%dy", years)
-	} else if months >= 12 && end.AddDate(0, -months, 0).Equal(start) {
-		return fmt.Sprintf("%dy", months/12)
-	} else if months > 0 && end.AddDate(0, -months, 0).Equal(start) {
-		return fmt.Sprintf("%dm", months)
-	} else {
-		return fmt.Sprintf("%dd", days)
+	if bom {
+		data = append([]byte("\xef\xbb\xbf"), data...)
 	}
+	return data
 }
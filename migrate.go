@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// runMigrate implements `vanitycal migrate`: it upgrades config files in place
+// to the current config version.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var paths configPaths
+	fs.Var(&paths, "config", "Path of a config file to migrate, repeatable")
+	format := fs.String("format", "", "Config format: 'toml', 'yaml' or 'json' (default: from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "Print the migrated config instead of writing it")
+	_ = fs.Parse(args)
+
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "migrate requires a config file")
+		fs.Usage()
+		return exitUsage
+	}
+
+	for _, path := range paths {
+		if path == "-" || vanitycal.IsURL(path) {
+			fmt.Fprintf(os.Stderr, "%s: only local files can be migrated\n", path)
+			return exitUsage
+		}
+		migrated, from, err := vanitycal.MigrateConfigFile(path, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return exitConfigError
+		}
+		switch {
+		case *dryRun:
+			fmt.Print(string(migrated))
+		case from == vanitycal.CurrentConfigVersion:
+			fmt.Printf("%s: already at version %d\n", path, vanitycal.CurrentConfigVersion)
+		default:
+			info, err := os.Stat(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitOutputError
+			}
+			if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitOutputError
+			}
+			fmt.Printf("%s: migrated from version %d to %d\n", path, from, vanitycal.CurrentConfigVersion)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+func TestMigrateKeepsComments(t *testing.T) {
+	for name, data := range map[string]string{
+		"config.toml": `# family milestones
+version = 1 # bumped by hand
+
+# decades only
+[patterns.decades]
+years = [10, 20] # round ones
+
+[[events]]
+title = "Us"   # aligned
+date = "2000-01-01"
+patterns = "decades"
+`,
+		"config.yaml": `# family milestones
+version: 1 # bumped by hand
+# decades only
+patterns:
+  decades:
+    years: [10, 20] # round ones
+events:
+  - title: Us   # aligned
+    date: "2000-01-01"
+    patterns: decades
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if code := runMigrate([]string{"-config", path}); code != exitOK {
+				t.Fatalf("migrate exited with %d", code)
+			}
+			migrated, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(data, "\n") {
+				_, comment, found := strings.Cut(line, "#")
+				if found && !strings.Contains(string(migrated), "#"+comment) {
+					t.Errorf("comment %q lost\n%s", comment, migrated)
+				}
+			}
+			if !strings.Contains(string(migrated), `title = "Us"   # aligned`) && !strings.Contains(string(migrated), "title: Us   # aligned") {
+				t.Errorf("formatting lost\n%s", migrated)
+			}
+			config, err := vanitycal.LoadConfig(path, vanitycal.LoadOptions{Strict: true})
+			if err != nil {
+				t.Fatalf("%v\n%s", err, migrated)
+			}
+			if config.Version != vanitycal.CurrentConfigVersion || config.Events[0].Profile != "decades" {
+				t.Errorf("version = %d, profile = %q, want %d and decades\n%s", config.Version, config.Events[0].Profile, vanitycal.CurrentConfigVersion, migrated)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"moul.io/vanitycal/pkg/datemath"
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// nextMilestone is the JSON output of `vanitycal next -json`.
+type nextMilestone struct {
+	Date     string `json:"date"`
+	Summary  string `json:"summary"`
+	Title    string `json:"title"`
+	Kind     string `json:"kind"`
+	DaysLeft int    `json:"days_left"`
+}
+
+// runNext implements `vanitycal next`: it prints the nearest upcoming
+// milestone (today included), e.g. for a status-bar widget.
+func runNext(args []string) int {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	var configs configFlags
+	configs.register(fs)
+	asJSON := fs.Bool("json", false, "Print the milestone as JSON")
+	_ = fs.Parse(args)
+
+	config, _, err := configs.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitValidationError
+	}
+
+	today := config.Today()
+	events = vanitycal.ExpandRecurring(events, today, today.AddDate(1, 0, 0))
+	var next *vanitycal.GeneratedEvent
+	for i, event := range events {
+		// events of extra feeds and next-up pointers aren't milestones.
+		if event.Kind == vanitycal.KindExternal || event.Kind == vanitycal.KindNextUp || event.Date.Before(today) {
+			continue
+		}
+		if next == nil || event.Date.Before(next.Date) {
+			next = &events[i]
+		}
+	}
+	if next == nil {
+		fmt.Fprintln(os.Stderr, "No upcoming milestone")
+		return exitGenerationWarning
+	}
+
+	if *asJSON {
+		data, err := json.Marshal(nextMilestone{
+			Date:     next.Date.Format("2006-01-02"),
+			Summary:  next.Summary,
+			Title:    next.Title,
+			Kind:     next.Kind,
+			DaysLeft: int(next.Date.Sub(today).Hours() / 24),
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitOutputError
+		}
+		fmt.Println(string(data))
+		return exitOK
+	}
+	fmt.Printf("%s  %s (%s)\n", vanitycal.FormatDate(next.Date, config.LanguageTag()), next.Summary, datemath.RelativeDay(next.Date, today))
+	return exitOK
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcVerifier validates bearer tokens issued by an OpenID Connect provider.
+// Only the signature, issuer, audience and validity window are checked.
+type oidcVerifier struct {
+	issuer   string
+	audience string
+	jwksURI  string
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// refreshing serializes the JWKS refetches, so that concurrent requests
+	// with an unknown key id share a single one.
+	refreshing sync.Mutex
+}
+
+// jwksRefreshInterval rate-limits JWKS refetches triggered by unknown key
+// ids, failed ones included.
+const jwksRefreshInterval = time.Minute
+
+func newOIDCVerifier(issuer, audience string) (*oidcVerifier, error) {
+	v := &oidcVerifier{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		audience: audience,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+		return nil, fmt.Errorf("OIDC discovery: issuer mismatch %q", discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery: missing jwks_uri")
+	}
+	v.jwksURI = discovery.JWKSURI
+	if err := v.refreshKeys(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// verify checks token and returns who it was issued to: the email claim,
+// or the subject when there's none.
+func (v *oidcVerifier) verify(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch header.Alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return "", errors.New("invalid signature")
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return "", errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return "", errors.New("invalid signature")
+		}
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	var claims struct {
+		Issuer    string          `json:"iss"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt int64           `json:"exp"`
+		NotBefore int64           `json:"nbf"`
+		Subject   string          `json:"sub"`
+		Email     string          `json:"email"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	if strings.TrimSuffix(claims.Issuer, "/") != v.issuer {
+		return "", errors.New("unexpected issuer")
+	}
+	if !audienceContains(claims.Audience, v.audience) {
+		return "", errors.New("unexpected audience")
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0)) {
+		return "", errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return "", errors.New("token not yet valid")
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	return claims.Subject, nil
+}
+
+func (v *oidcVerifier) key(kid string) (crypto.PublicKey, error) {
+	if key, found, _ := v.cachedKey(kid); found {
+		return key, nil
+	}
+	// the provider may have rotated its keys. Requests waiting here find the
+	// keys another one just fetched, or a fresh fetchedAt after it failed.
+	v.refreshing.Lock()
+	defer v.refreshing.Unlock()
+	key, found, stale := v.cachedKey(kid)
+	if found {
+		return key, nil
+	}
+	if stale {
+		if err := v.refreshKeys(); err != nil {
+			return nil, err
+		}
+		if key, found, _ = v.cachedKey(kid); found {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// cachedKey returns the key kid from the last JWKS fetch, and whether it is
+// old enough to be refetched.
+func (v *oidcVerifier) cachedKey(kid string) (crypto.PublicKey, bool, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, found := v.keys[kid]
+	return key, found, time.Since(v.fetchedAt) > jwksRefreshInterval
+}
+
+func (v *oidcVerifier) refreshKeys() error {
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	v.mu.Lock()
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	if err := v.getJSON(v.jwksURI, &jwks); err != nil {
+		return fmt.Errorf("OIDC keys: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		switch {
+		case jwk.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *oidcVerifier) getJSON(url string, target interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func decodeJWTPart(part string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token: %w", err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("malformed token: %w", err)
+	}
+	return nil
+}
+
+// audienceContains handles both the string and array forms of the aud claim.
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == audience
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testProvider is an OpenID Connect provider serving discovery and the
+// public key of its RSA signing key.
+type testProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// keyFetches counts the requests for the public keys.
+	keyFetches atomic.Int32
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": p.URL, "jwks_uri": p.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.keyFetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// unsigned returns the header and claims of a token, without signature.
+func unsigned(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return encode(map[string]string{"alg": alg, "kid": kid}) + "." + encode(claims)
+}
+
+// sign returns an RS256 token with claims, signed by key under kid.
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	payload := unsigned(t, "RS256", kid, claims)
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCVerify(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier, err := newOIDCVerifier(provider.URL, "vanitycal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": provider.URL, "aud": "vanitycal", "exp": now.Add(time.Hour).Unix()}
+		for key, value := range overrides {
+			c[key] = value
+		}
+		return c
+	}
+
+	for _, tt := range []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid", sign(t, provider.key, "k1", claims(nil)), ""},
+		{"audience list", sign(t, provider.key, "k1", claims(map[string]interface{}{"aud": []string{"other", "vanitycal"}})), ""},
+		{"bad signature", sign(t, other, "k1", claims(nil)), "invalid signature"},
+		{"expired", sign(t, provider.key, "k1", claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})), "token expired"},
+		{"no expiry", sign(t, provider.key, "k1", claims(map[string]interface{}{"exp": 0})), "token expired"},
+		{"not yet valid", sign(t, provider.key, "k1", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), "token not yet valid"},
+		{"wrong audience", sign(t, provider.key, "k1", claims(map[string]interface{}{"aud": "other"})), "unexpected audience"},
+		{"wrong issuer", sign(t, provider.key, "k1", claims(map[string]interface{}{"iss": "https://evil.example.com"})), "unexpected issuer"},
+		{"unknown kid", sign(t, provider.key, "k2", claims(nil)), `unknown key id "k2"`},
+		{"alg none", unsigned(t, "none", "k1", claims(nil)) + ".", `unsupported algorithm "none"`},
+		{"alg HS256", unsigned(t, "HS256", "k1", claims(nil)) + "." + base64.RawURLEncoding.EncodeToString([]byte("mac")), `unsupported algorithm "HS256"`},
+		{"malformed", "not-a-token", "malformed token"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.verify(tt.token, now)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("verify() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("verify() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBearerAuthSameOnEveryRoute(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier, err := newOIDCVerifier(provider.URL, "vanitycal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := bearerAuth{token: "static-token", verifier: verifier}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	oidcToken := sign(t, provider.key, "k1", map[string]interface{}{"iss": provider.URL, "aud": "vanitycal", "exp": time.Now().Add(time.Hour).Unix()})
+
+	for _, route := range []string{"/calendar.ics", "/api/calendars/team/events"} {
+		for token, want := range map[string]int{
+			"":             http.StatusUnauthorized,
+			"wrong":        http.StatusUnauthorized,
+			"static-token": http.StatusOK,
+			oidcToken:      http.StatusOK,
+		} {
+			r := httptest.NewRequest(http.MethodPost, route, nil)
+			if token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			auth.middleware(ok).ServeHTTP(rec, r)
+			if rec.Code != want {
+				t.Errorf("%s with token %.10q: status = %d, want %d", route, token, rec.Code, want)
+			}
+		}
+	}
+}
+
+func TestOIDCRefreshOnce(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier, err := newOIDCVerifier(provider.URL, "vanitycal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, provider.key, "k2", map[string]interface{}{"iss": provider.URL, "aud": "vanitycal", "exp": time.Now().Add(time.Hour).Unix()})
+	verifier.mu.Lock()
+	verifier.fetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	verifier.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := verifier.verify(token, time.Now()); err == nil {
+				t.Error("verify() = nil, want an unknown key id error")
+			}
+		}()
+	}
+	wg.Wait()
+	// one fetch on creation, a single one for the concurrent requests.
+	if fetches := provider.keyFetches.Load(); fetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", fetches)
+	}
+}
@@ -0,0 +1,305 @@
+// Package datemath holds the calendar arithmetic vanity milestones are built
+// on. It works on plain dates (midnight UTC) and has no notion of config.
+package datemath
+
+import (
+	"fmt"
+	"time"
+)
+
+// Anniversaries returns date itself followed by the dates the given
+// numbers of years, days and months later.
+func Anniversaries(date time.Time, years, months, days []int) []time.Time {
+	anniversaries := []time.Time{date} // d day
+	for _, n := range years {
+		anniversaries = append(anniversaries, date.AddDate(n, 0, 0))
+	}
+	for _, n := range days {
+		anniversaries = append(anniversaries, date.AddDate(0, 0, n))
+	}
+	for _, n := range months {
+		anniversaries = append(anniversaries, date.AddDate(0, n, 0))
+	}
+	return anniversaries
+}
+
+// Leap-day policies decide where the yearly anniversaries of February 29
+// fall in common years.
+const (
+	LeapDayMar01 = "mar01"
+	LeapDayFeb28 = "feb28"
+)
+
+// AddYears returns the date n years after date. Anniversaries of February 29
+// fall on March 1 in common years, or on February 28 with LeapDayFeb28.
+func AddYears(date time.Time, n int, leapDayPolicy string) time.Time {
+	anniv := date.AddDate(n, 0, 0)
+	if leapDayPolicy == LeapDayFeb28 && anniv.Day() != date.Day() {
+		return anniv.AddDate(0, 0, -1)
+	}
+	return anniv
+}
+
+// Shift rules move a date to the day it is observed on.
+const (
+	// ShiftNextWeekday moves Saturdays and Sundays to the next Monday.
+	ShiftNextWeekday = "next_weekday"
+	// ShiftNearestSaturday moves any day to the closest Saturday: Sunday
+	// to Tuesday move back, Wednesday to Friday forward.
+	ShiftNearestSaturday = "nearest_saturday"
+)
+
+// Shift returns the day date is observed on under rule, date itself for
+// unknown rules.
+func Shift(date time.Time, rule string) time.Time {
+	switch rule {
+	case ShiftNextWeekday:
+		switch date.Weekday() {
+		case time.Saturday:
+			return date.AddDate(0, 0, 2)
+		case time.Sunday:
+			return date.AddDate(0, 0, 1)
+		}
+	case ShiftNearestSaturday:
+		offset := int(time.Saturday - date.Weekday())
+		if offset > 3 {
+			offset -= 7
+		}
+		return date.AddDate(0, 0, offset)
+	}
+	return date
+}
+
+// Countdowns returns the dates N days before date, skipping duplicates.
+func Countdowns(date time.Time, days []int) []time.Time {
+	seen := map[int]bool{}
+	countdowns := []time.Time{}
+	for _, n := range days {
+		if n <= 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		countdowns = append(countdowns, date.AddDate(0, 0, -n))
+	}
+	return countdowns
+}
+
+// Progress returns the percentage of the waiting period between since and
+// target already elapsed on day.
+func Progress(since, day, target time.Time) (int, bool) {
+	total := target.Sub(since)
+	if total <= 0 || day.Before(since) {
+		return 0, false
+	}
+	return int(day.Sub(since) * 100 / total), true
+}
+
+// FormatDuration formats the time elapsed from start to end in the largest
+// whole unit, e.g. "3y", "6m" or "100d".
+func FormatDuration(start, end time.Time) string {
+	years := end.Year() - start.Year()
+	months := int(end.Sub(start).Hours() / (24 * 30))
+	days := int(end.Sub(start).Hours() / 24)
+
+	if end == start {
+		return "D-DAY"
+	}
+	if years > 0 && (end.AddDate(-years, 0, 0).Equal(start) || isLeapDayAnniversary(start, end)) {
+		return fmt.Sprintf("%dy", years)
+	} else if months >= 12 && end.AddDate(0, -months, 0).Equal(start) {
+		return fmt.Sprintf("%dy", months/12)
+	} else if months > 0 && end.AddDate(0, -months, 0).Equal(start) {
+		return fmt.Sprintf("%dm", months)
+	} else {
+		return fmt.Sprintf("%dd", days)
+	}
+}
+
+// isLeapDayAnniversary reports whether end is a common-year anniversary of a
+// February 29 start, under either leap-day policy.
+func isLeapDayAnniversary(start, end time.Time) bool {
+	if start.Month() != time.February || start.Day() != 29 || end.Year() <= start.Year() {
+		return false
+	}
+	years := end.Year() - start.Year()
+	return end.Equal(AddYears(start, years, LeapDayMar01)) || end.Equal(AddYears(start, years, LeapDayFeb28))
+}
+
+// WeekAnniversaries returns the dates N weeks after date.
+func WeekAnniversaries(date time.Time, weeks []int) []time.Time {
+	anniversaries := []time.Time{}
+	for _, n := range weeks {
+		anniversaries = append(anniversaries, date.AddDate(0, 0, 7*n))
+	}
+	return anniversaries
+}
+
+// FormatWeeks formats the time elapsed from start to end in weeks, e.g.
+// "1000w", for milestones counted in weeks.
+func FormatWeeks(start, end time.Time) string {
+	return fmt.Sprintf("%dw", int(end.Sub(start).Hours()/(24*7)))
+}
+
+// Elapsed splits the time from start to end into whole years, months and
+// days, the way ages are counted. A month after the 31st is the last day of
+// shorter months.
+func Elapsed(start, end time.Time) (years, months, days int) {
+	total := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+	if total > 0 && addMonths(start, total).After(end) {
+		total--
+	}
+	days = int(end.Sub(addMonths(start, total)).Hours() / 24)
+	return total / 12, total % 12, days
+}
+
+// addMonths returns the date n months after date, on the last day of the
+// month when it is shorter.
+func addMonths(date time.Time, n int) time.Time {
+	first := time.Date(date.Year(), date.Month()+time.Month(n), 1, 0, 0, 0, 0, date.Location())
+	day := date.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+}
+
+// FormatCountdown formats the number of days left from day to target,
+// e.g. "D-7".
+func FormatCountdown(day, target time.Time) string {
+	days := int(target.Sub(day).Hours() / 24)
+	return fmt.Sprintf("D-%d", days)
+}
+
+// RelativeDay describes day relative to today for notification and agenda
+// texts, e.g. "today", "tomorrow", "in 3 days" or "2 days ago". Both dates
+// are compared as calendar days.
+func RelativeDay(day, today time.Time) string {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	switch days := int(day.Sub(today).Hours() / 24); {
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "tomorrow"
+	case days == -1:
+		return "yesterday"
+	case days > 0:
+		return fmt.Sprintf("in %d days", days)
+	default:
+		return fmt.Sprintf("%d days ago", -days)
+	}
+}
+
+// YearlyOccurrences returns the occurrences of a yearless date in the
+// previous, current and next year.
+func YearlyOccurrences(month time.Month, day int, now time.Time) []time.Time {
+	occurrences := []time.Time{}
+	for year := now.Year() - 1; year <= now.Year()+1; year++ {
+		occurrences = append(occurrences, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+	return occurrences
+}
+
+// GoldenBirthday returns the anniversary on which the age equals the day of
+// month, see AddYears for leapDayPolicy.
+func GoldenBirthday(date time.Time, leapDayPolicy string) time.Time {
+	return AddYears(date, date.Day(), leapDayPolicy)
+}
+
+// PalindromeDates returns the dates in [from, until] whose YYYYMMDD form
+// reads the same both ways; there is at most one per year.
+func PalindromeDates(from, until time.Time) []time.Time {
+	dates := []time.Time{}
+	for year := from.Year(); year <= until.Year() && year <= 9999; year++ {
+		y := fmt.Sprintf("%04d", year)
+		month := int(y[3]-'0')*10 + int(y[2]-'0')
+		day := int(y[1]-'0')*10 + int(y[0]-'0')
+		candidate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, from.Location())
+		if candidate.Month() != time.Month(month) || candidate.Day() != day {
+			continue // not a valid date
+		}
+		if candidate.Before(from) || candidate.After(until) {
+			continue
+		}
+		dates = append(dates, candidate)
+	}
+	return dates
+}
+
+// AggregateDate returns the first day on which the ages (in days) of the
+// sorted anchors add up to total. Anchors only count once they've happened.
+func AggregateDate(anchors []time.Time, total int) time.Time {
+	origin := anchors[0]
+	offsets := make([]int, len(anchors))
+	for i, anchor := range anchors {
+		offsets[i] = int(anchor.Sub(origin).Hours() / 24)
+	}
+
+	// with the first n anchors counted, the sum of ages on day t (relative to
+	// the first anchor) is n*t - sum(offsets[:n]).
+	sum := 0
+	for n := 1; n <= len(offsets); n++ {
+		sum += offsets[n-1]
+		t := (total + sum + n - 1) / n // first day reaching the total
+		if n == len(offsets) || t <= offsets[n] {
+			return origin.AddDate(0, 0, t)
+		}
+	}
+	return origin // unreachable
+}
+
+// NextLeapDay returns the first February 29 strictly after date.
+func NextLeapDay(date time.Time) time.Time {
+	for year := date.Year(); ; year++ {
+		candidate := time.Date(year, time.February, 29, 0, 0, 0, 0, date.Location())
+		if candidate.Month() == time.February && candidate.After(date) {
+			return candidate
+		}
+	}
+}
+
+// SameWeekdayAnniversaries returns the first count anniversaries of date
+// falling on the same weekday as date itself.
+func SameWeekdayAnniversaries(date time.Time, count int) []time.Time {
+	anniversaries := []time.Time{}
+	// the weekday of a given date cycles every 400 years.
+	for years := 1; len(anniversaries) < count && years <= 400; years++ {
+		anniversary := date.AddDate(years, 0, 0)
+		if anniversary.Day() != date.Day() {
+			continue // February 29 in a common year
+		}
+		if anniversary.Weekday() == date.Weekday() {
+			anniversaries = append(anniversaries, anniversary)
+		}
+	}
+	return anniversaries
+}
+
+// RoundNumbers returns the "nice" numbers between min and max: 1, 2 and 5
+// times a power of ten, e.g. 1000, 2000, 5000, 10000...
+func RoundNumbers(min, max int) []int {
+	numbers := []int{}
+	for power := 1; power <= max; power *= 10 {
+		for _, factor := range []int{1, 2, 5} {
+			if n := factor * power; n >= min && n <= max {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	return numbers
+}
+
+// Ordinal formats n as an English ordinal, e.g. "1st", "2nd" or "11th".
+func Ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
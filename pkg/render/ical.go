@@ -0,0 +1,308 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+)
+
+// ICal writes the events as an iCalendar 2.0 file.
+func ICal(calendar Calendar, events []Event, output io.Writer) error {
+	if _, err := time.LoadLocation(calendar.Timezone); err != nil {
+		return fmt.Errorf("Invalid timezone: %w", err)
+	}
+
+	cal := ical.NewCalendar()
+	cal.SetMethod(ical.MethodPublish)
+	if calendar.ProdID != "" {
+		cal.SetProductId(calendar.ProdID)
+	}
+	cal.SetName(calendar.Name)
+	if calendar.Description != "" {
+		cal.SetDescription(calendar.Description)
+		cal.SetXWRCalDesc(calendar.Description)
+	}
+	cal.SetTimezoneId(calendar.Timezone)
+	cal.SetTzid(calendar.Timezone)
+	cal.SetCalscale("GREGORIAN")
+	cal.SetLastModified(calendar.LastModified)
+	if strings.HasPrefix(calendar.Color, "#") {
+		// COLOR only takes CSS color names (RFC 7986).
+		cal.CalendarProperties = append(cal.CalendarProperties, ical.CalendarProperty{BaseProperty: ical.BaseProperty{
+			IANAToken:      "X-APPLE-CALENDAR-COLOR",
+			Value:          calendar.Color,
+			ICalParameters: map[string][]string{},
+		}})
+	} else if calendar.Color != "" {
+		cal.SetColor(calendar.Color)
+	}
+	if calendar.RefreshInterval > 0 {
+		cal.SetRefreshInterval(formatDuration(calendar.RefreshInterval))
+		cal.SetXPublishedTTL(formatDuration(calendar.RefreshInterval))
+	}
+
+	// strict clients (Outlook) need the definition of the timezone timed
+	// events refer to.
+	for _, event := range events {
+		if !event.Start.IsZero() {
+			year := calendar.LastModified.Year()
+			if calendar.LastModified.IsZero() {
+				year = time.Now().Year()
+			}
+			timezone, err := vtimezone(calendar.Timezone, year)
+			if err != nil {
+				return fmt.Errorf("Invalid timezone: %w", err)
+			}
+			cal.AddVTimezone(timezone)
+			break
+		}
+	}
+
+	replaced := map[string]bool{}
+	for _, extra := range calendar.Extra {
+		replaced[extra.Id()] = true
+	}
+	for _, event := range events {
+		if replaced[event.UID] {
+			continue
+		}
+		if event.Todo {
+			todo := cal.AddTodo(event.UID)
+			setComponent(&todo.ComponentBase, calendar, event)
+			// only CANCELLED is shared by events and to-dos.
+			if event.Status == string(ical.ObjectStatusCancelled) {
+				todo.SetStatus(ical.ObjectStatusCancelled)
+			}
+			addAlarms(todo.AddAlarm, event)
+			if !event.Start.IsZero() {
+				todo.SetProperty(ical.ComponentPropertyDue, event.Start.Format("20060102T150405"), withTZID(calendar.Timezone))
+			} else if calendar.ForceUTCAllDay {
+				todo.SetProperty(ical.ComponentPropertyDue, event.Date.Format("20060102T000000Z"))
+			} else {
+				todo.SetProperty(ical.ComponentPropertyDue, event.Date.Format("20060102"), ical.WithValue("DATE"))
+			}
+			continue
+		}
+
+		icalEvent := cal.AddEvent(event.UID)
+		setComponent(&icalEvent.ComponentBase, calendar, event)
+		if event.Busy {
+			icalEvent.SetTimeTransparency(ical.TransparencyOpaque)
+		} else {
+			icalEvent.SetTimeTransparency(ical.TransparencyTransparent)
+		}
+		if event.Status != "" {
+			icalEvent.SetStatus(ical.ObjectStatus(event.Status))
+		}
+		addAlarms(icalEvent.AddAlarm, event)
+
+		// fullday
+		if !event.Start.IsZero() {
+			icalEvent.SetProperty(ical.ComponentPropertyDtStart, event.Start.Format("20060102T150405"), withTZID(calendar.Timezone))
+			icalEvent.SetProperty(ical.ComponentPropertyDtEnd, event.End.Format("20060102T150405"), withTZID(calendar.Timezone))
+		} else if calendar.ForceUTCAllDay {
+			icalEvent.SetProperty(ical.ComponentPropertyDtStart, event.Date.Format("20060102T000000Z"))
+			icalEvent.SetProperty(ical.ComponentPropertyDtEnd, lastDay(event).AddDate(0, 0, 1).Format("20060102T000000Z"))
+		} else {
+			icalEvent.SetProperty(ical.ComponentPropertyDtStart, event.Date.Format("20060102"), ical.WithValue("DATE"))
+			if !event.Until.IsZero() {
+				// DTEND is exclusive.
+				icalEvent.SetProperty(ical.ComponentPropertyDtEnd, event.Until.AddDate(0, 0, 1).Format("20060102"), ical.WithValue("DATE"))
+			}
+		}
+
+		// XXX: specific hours
+		//icalEvent.SetStartAt(event.Date)
+		//icalEvent.SetEndAt(event.Date.Add(24 * time.Hour))
+
+		if event.Journal != "" {
+			addJournal(cal, calendar, event)
+		}
+	}
+
+	for _, extra := range calendar.Extra {
+		cal.AddVEvent(extra)
+	}
+
+	_, err := output.Write([]byte(cal.Serialize()))
+	return err
+}
+
+// setComponent sets the properties shared by the VEVENT and VTODO of event.
+func setComponent(component *ical.ComponentBase, calendar Calendar, event Event) {
+	component.SetSummary(event.Summary)
+	if event.Description != "" {
+		component.SetDescription(event.Description)
+	}
+	// DTSTAMP is required, fall back to the calendar modification time
+	// without state.
+	stamp := event.Stamp
+	if stamp.IsZero() {
+		stamp = calendar.LastModified
+	}
+	if !stamp.IsZero() {
+		component.SetDtStampTime(stamp)
+	}
+	if event.RRule != "" {
+		component.AddRrule(event.RRule)
+	}
+	// RDATE and EXDATE take the value type of DTSTART.
+	rdates, exdates := event.RDates, event.ExDates
+	datesLayout, datesParam := "20060102", ical.WithValue("DATE")
+	if !event.Start.IsZero() {
+		rdates, exdates = atTimeOf(rdates, event.Start), atTimeOf(exdates, event.Start)
+		datesLayout, datesParam = "20060102T150405", withTZID(calendar.Timezone)
+	}
+	if len(rdates) > 0 {
+		component.AddProperty(ical.ComponentPropertyRdate, joinDates(rdates, datesLayout, ","), datesParam)
+	}
+	if len(exdates) > 0 {
+		component.AddProperty(ical.ComponentPropertyExdate, joinDates(exdates, datesLayout, ","), datesParam)
+	}
+	if event.Sequence > 0 {
+		component.SetSequence(event.Sequence)
+	}
+	if event.Class != "" {
+		component.SetClass(ical.Classification(event.Class))
+	}
+	if event.Organizer != "" {
+		component.SetOrganizer(event.Organizer)
+	}
+	for _, attendee := range event.Attendees {
+		component.AddAttendee(attendee)
+	}
+	if event.Location != "" {
+		component.SetLocation(event.Location)
+	}
+	if len(event.Geo) == 2 {
+		component.SetProperty(ical.ComponentPropertyGeo, formatCoordinate(event.Geo[0])+";"+formatCoordinate(event.Geo[1]))
+	}
+	if event.URL != "" {
+		component.SetURL(event.URL)
+	}
+	// one property per category, as TEXT escaping would quote the
+	// commas of a list.
+	for _, category := range event.Categories {
+		component.AddProperty(ical.ComponentPropertyCategories, category)
+	}
+	if event.Source != "" {
+		component.SetProperty("X-VANITYCAL-SOURCE", event.Source)
+	}
+	if event.Confidence != "" {
+		component.SetProperty("X-VANITYCAL-CONFIDENCE", event.Confidence)
+	}
+}
+
+// addJournal writes the VJOURNAL memory entry of event, dated like it.
+func addJournal(cal *ical.Calendar, calendar Calendar, event Event) {
+	journal := cal.AddJournal("journal-" + event.UID)
+	journal.SetSummary(event.Summary)
+	description := event.Journal
+	if event.Description != "" {
+		description += "\n\n" + event.Description
+	}
+	journal.SetDescription(description)
+	journal.SetStatus(ical.ObjectStatusFinal)
+	if !event.Stamp.IsZero() {
+		journal.SetDtStampTime(event.Stamp)
+	}
+	if event.Sequence > 0 {
+		journal.SetSequence(event.Sequence)
+	}
+	if event.Class != "" {
+		journal.SetClass(ical.Classification(event.Class))
+	}
+	for _, category := range event.Categories {
+		journal.AddProperty(ical.ComponentPropertyCategories, category)
+	}
+	if !event.Start.IsZero() {
+		journal.SetProperty(ical.ComponentPropertyDtStart, event.Start.Format("20060102T150405"), withTZID(calendar.Timezone))
+	} else if calendar.ForceUTCAllDay {
+		journal.SetProperty(ical.ComponentPropertyDtStart, event.Date.Format("20060102T000000Z"))
+	} else {
+		journal.SetProperty(ical.ComponentPropertyDtStart, event.Date.Format("20060102"), ical.WithValue("DATE"))
+	}
+}
+
+// addAlarms adds the VALARMs of event with add.
+func addAlarms(add func() *ical.VAlarm, event Event) {
+	for _, before := range event.Alarms {
+		alarm := add()
+		alarm.SetAction(ical.ActionDisplay)
+		alarm.SetTrigger(formatTrigger(before))
+		alarm.SetProperty(ical.ComponentPropertyDescription, event.Summary)
+	}
+}
+
+func withTZID(timezone string) ical.PropertyParameter {
+	return &ical.KeyValues{Key: string(ical.ParameterTzid), Value: []string{timezone}}
+}
+
+// lastDay returns the last day of an all-day event.
+func lastDay(event Event) time.Time {
+	if !event.Until.IsZero() {
+		return event.Until
+	}
+	return event.Date
+}
+
+// atTimeOf returns the days at the time of day of start.
+func atTimeOf(days []time.Time, start time.Time) []time.Time {
+	times := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		times = append(times, time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location()))
+	}
+	return times
+}
+
+func joinDates(dates []time.Time, layout, separator string) string {
+	formatted := make([]string, 0, len(dates))
+	for _, date := range dates {
+		formatted = append(formatted, date.Format(layout))
+	}
+	return strings.Join(formatted, separator)
+}
+
+// formatTrigger formats an alarm going off before the start of an event as
+// an iCalendar duration, e.g. "-P1W", "-P1D" or "-PT1H30M".
+func formatTrigger(before time.Duration) string {
+	if before == 0 {
+		return "PT0S"
+	}
+	return "-" + formatDuration(before)
+}
+
+// formatDuration formats a whole number of minutes as an iCalendar
+// duration, e.g. "P1W", "P1D" or "PT1H30M".
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d == 0:
+		return "PT0S"
+	case d%(7*day) == 0:
+		return fmt.Sprintf("P%dW", d/(7*day))
+	case d%day == 0:
+		return fmt.Sprintf("P%dD", d/day)
+	}
+	formatted := "P"
+	if days := d / day; days > 0 {
+		formatted += fmt.Sprintf("%dD", days)
+	}
+	formatted += "T"
+	if hours := d % day / time.Hour; hours > 0 {
+		formatted += fmt.Sprintf("%dH", hours)
+	}
+	if minutes := d % time.Hour / time.Minute; minutes > 0 {
+		formatted += fmt.Sprintf("%dM", minutes)
+	}
+	return formatted
+}
+
+// formatCoordinate formats a GEO latitude or longitude without exponent.
+func formatCoordinate(coordinate float64) string {
+	return strconv.FormatFloat(coordinate, 'f', -1, 64)
+}
@@ -0,0 +1,95 @@
+// Package render serializes dated events as calendar files: iCalendar 2.0
+// (ICS) and the legacy vCalendar 1.0 (VCS).
+//
+// Text is written as given; callers normalize it beforehand.
+package render
+
+import (
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+)
+
+// Calendar holds the calendar-wide settings.
+type Calendar struct {
+	Name string
+	// Description is written as DESCRIPTION and X-WR-CALDESC when set.
+	Description string
+	// ProdID identifies the publisher (PRODID).
+	ProdID string
+	// Timezone is an IANA timezone name, e.g. "Europe/Paris".
+	Timezone string
+	// ForceUTCAllDay writes all-day events as UTC midnight-to-midnight
+	// date-times, for clients that mishandle VALUE=DATE.
+	ForceUTCAllDay bool
+	// LastModified is written as the calendar LAST-MODIFIED.
+	LastModified time.Time
+	// Color is a CSS color name, written as COLOR, or "#rrggbb", written
+	// as X-APPLE-CALENDAR-COLOR.
+	Color string
+	// RefreshInterval, in whole minutes, tells subscribers how often to
+	// re-fetch the feed (REFRESH-INTERVAL and X-PUBLISHED-TTL).
+	RefreshInterval time.Duration
+	// Extra are hand-written events copied as is in ICS output, replacing
+	// events with the same UID. VCS output ignores them.
+	Extra []*ical.VEvent
+}
+
+// Event is an all-day calendar entry.
+type Event struct {
+	// Todo events are written as a VTODO due on Date (or Start) rather
+	// than a VEVENT.
+	Todo bool
+
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+
+	// Source and Confidence are exported as X-VANITYCAL-* properties.
+	Source     string
+	Confidence string
+
+	// Location, Geo ([latitude, longitude], nil when unknown) and URL
+	// locate the event.
+	Location string
+	Geo      []float64
+	URL      string
+	// Categories are written as CATEGORIES.
+	Categories []string
+
+	// Start and End are set for timed events, in the calendar timezone;
+	// other events last the whole Date.
+	Start time.Time
+	End   time.Time
+	// Until is the last day of all-day events spanning several days.
+	Until time.Time
+	// Busy events are OPAQUE, the others TRANSPARENT to free/busy.
+	Busy bool
+	// Status and Class are written as STATUS and CLASS when set.
+	Status string
+	Class  string
+	// Organizer and Attendees are e-mail addresses.
+	Organizer string
+	Attendees []string
+
+	// RRule is the recurrence rule of the event, e.g. "FREQ=YEARLY".
+	RRule string
+	// RDates are additional occurrences of the event, and ExDates the
+	// occurrences of the rule to skip.
+	RDates  []time.Time
+	ExDates []time.Time
+
+	// Alarms are how long before the start of the event reminders go off.
+	Alarms []time.Duration
+
+	// Journal, when set, is written as a VJOURNAL memory entry on the day
+	// of the event, followed by its description.
+	Journal string
+
+	// Sequence is the revision of the event (SEQUENCE), omitted when zero,
+	// and Stamp when it last changed (DTSTAMP), the calendar LastModified
+	// when zero.
+	Sequence int
+	Stamp    time.Time
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+)
+
+// VCS writes the events as a vCalendar 1.0 file, for old car systems
+// and feature phones that don't understand iCalendar.
+func VCS(calendar Calendar, events []Event, output io.Writer) error {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\r\n", args...)
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:1.0")
+	prodID := calendar.ProdID
+	if prodID == "" {
+		prodID = "-//moul.io//vanitycal//EN"
+	}
+	line("PRODID:%s", prodID)
+	// vCalendar 1.0 has no journal entries, Event.Journal is ignored.
+	for _, event := range events {
+		component := "VEVENT"
+		if event.Todo {
+			component = "VTODO"
+		}
+		line("BEGIN:%s", component)
+		line("UID:%s", event.UID)
+		line("SUMMARY%s", vcsText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION%s", vcsText(event.Description))
+		}
+		if !event.Todo {
+			// 0 is opaque, other values transparent.
+			transparency := 1
+			if event.Busy {
+				transparency = 0
+			}
+			line("TRANSP:%d", transparency)
+		}
+		// vCalendar 1.0 has no cancelled status.
+		if event.Status != "" && event.Status != "CANCELLED" {
+			line("STATUS:%s", event.Status)
+		}
+		if event.Class != "" {
+			line("CLASS:%s", event.Class)
+		}
+		if event.Organizer != "" {
+			line("ATTENDEE;ROLE=ORGANIZER:%s", event.Organizer)
+		}
+		for _, attendee := range event.Attendees {
+			line("ATTENDEE:%s", attendee)
+		}
+		if event.Location != "" {
+			line("LOCATION%s", vcsText(event.Location))
+		}
+		if event.URL != "" {
+			line("URL:%s", event.URL)
+		}
+		if len(event.Categories) > 0 {
+			line("CATEGORIES%s", vcsText(strings.Join(event.Categories, ";")))
+		}
+		if event.Todo {
+			due := event.Start
+			if due.IsZero() {
+				due = event.Date
+			}
+			line("DUE:%s", due.Format("20060102T150405"))
+		} else if !event.Start.IsZero() {
+			line("DTSTART:%s", event.Start.Format("20060102T150405"))
+			line("DTEND:%s", event.End.Format("20060102T150405"))
+		} else {
+			// vCalendar 1.0 has no all-day value type; span the whole day instead.
+			line("DTSTART:%sT000000", event.Date.Format("20060102"))
+			line("DTEND:%sT235959", lastDay(event).Format("20060102"))
+		}
+		if rule := vcsRule(event.RRule); rule != "" {
+			line("RRULE:%s", rule)
+		}
+		rdates, exdates := event.RDates, event.ExDates
+		if !event.Start.IsZero() {
+			rdates, exdates = atTimeOf(rdates, event.Start), atTimeOf(exdates, event.Start)
+		}
+		if len(rdates) > 0 {
+			line("RDATE:%s", joinDates(rdates, "20060102T150405", ";"))
+		}
+		if len(exdates) > 0 {
+			line("EXDATE:%s", joinDates(exdates, "20060102T150405", ";"))
+		}
+		start := event.Start
+		if start.IsZero() {
+			start = event.Date
+		}
+		for _, before := range event.Alarms {
+			// vCalendar 1.0 alarms are absolute, they only fire for the first
+			// occurrence of recurring events.
+			line("DALARM:%s", start.Add(-before).Format("20060102T150405"))
+		}
+		line("END:%s", component)
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(output, b.String())
+	return err
+}
+
+// vcsRule converts the yearly rules vanitycal generates to the vCalendar
+// 1.0 basic recurrence grammar: yearly by month, N times or forever (#0),
+// and the leap-day rules by day of year or last day of February.
+func vcsRule(rrule string) string {
+	switch {
+	case rrule == "FREQ=YEARLY":
+		return "YM1 #0"
+	case strings.HasPrefix(rrule, "FREQ=YEARLY;COUNT="):
+		return "YM1 #" + strings.TrimPrefix(rrule, "FREQ=YEARLY;COUNT=")
+	case rrule == "FREQ=YEARLY;BYYEARDAY=60":
+		return "YD1 60 #0"
+	case rrule == "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1":
+		// every 12 months on the last day of the month, from a February.
+		return "MD12 1- #0"
+	}
+	return ""
+}
+
+// vcsText returns the parameters and value of a text property: plain ASCII is
+// written as is, anything else is quoted-printable UTF-8.
+func vcsText(s string) string {
+	s = strings.NewReplacer("\r\n", "\n", "\n", " ").Replace(s)
+	if isPlainASCII(s) {
+		return ":" + s
+	}
+
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	// vCalendar soft line breaks are "=" followed by CRLF, as in MIME.
+	return ";CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:" + buf.String()
+}
+
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf || s[i] == '=' {
+			return false
+		}
+	}
+	return true
+}
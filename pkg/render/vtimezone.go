@@ -0,0 +1,111 @@
+package render
+
+import (
+	"fmt"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+)
+
+// vtimezoneEpoch is the year VTIMEZONE observances start from, as most
+// calendar software does.
+const vtimezoneEpoch = 1970
+
+// vtimezone returns the VTIMEZONE describing location with the daylight
+// saving rules it follows in year, expressed as yearly RRULEs. Rules that
+// aren't "Nth (or last) weekday of a month" are approximated by them.
+func vtimezone(name string, year int) (*ical.VTimezone, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	timezone := ical.NewTimezone(name)
+
+	transitions := zoneTransitions(location, year)
+	if len(transitions) == 0 {
+		abbreviation, offset := time.Date(year, time.January, 1, 0, 0, 0, 0, location).Zone()
+		standard := &ical.Standard{}
+		standard.SetProperty(ical.ComponentPropertyDtStart, fmt.Sprintf("%d0101T000000", vtimezoneEpoch))
+		standard.SetProperty(ical.ComponentProperty(ical.PropertyTzoffsetfrom), formatOffset(offset))
+		standard.SetProperty(ical.ComponentProperty(ical.PropertyTzoffsetto), formatOffset(offset))
+		standard.SetProperty(ical.ComponentProperty(ical.PropertyTzname), abbreviation)
+		timezone.Components = append(timezone.Components, standard)
+		return timezone, nil
+	}
+
+	for _, transition := range transitions {
+		_, before := transition.Add(-time.Second).In(location).Zone()
+		after := transition.In(location)
+		abbreviation, offset := after.Zone()
+		// DTSTART is the wall clock time before the transition.
+		local := transition.Add(time.Duration(before) * time.Second).UTC()
+		n := (local.Day()-1)/7 + 1
+		if local.AddDate(0, 0, 7).Month() != local.Month() {
+			n = -1
+		}
+		first := nthWeekday(vtimezoneEpoch, local.Month(), local.Weekday(), n)
+
+		var observance *ical.ComponentBase
+		if after.IsDST() {
+			daylight := &ical.Daylight{}
+			timezone.Components = append(timezone.Components, daylight)
+			observance = &daylight.ComponentBase
+		} else {
+			standard := &ical.Standard{}
+			timezone.Components = append(timezone.Components, standard)
+			observance = &standard.ComponentBase
+		}
+		observance.SetProperty(ical.ComponentPropertyDtStart, first.Format("20060102")+local.Format("T150405"))
+		observance.SetProperty(ical.ComponentPropertyRrule, fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", local.Month(), n, weekdayCodes[local.Weekday()]))
+		observance.SetProperty(ical.ComponentProperty(ical.PropertyTzoffsetfrom), formatOffset(before))
+		observance.SetProperty(ical.ComponentProperty(ical.PropertyTzoffsetto), formatOffset(offset))
+		observance.SetProperty(ical.ComponentProperty(ical.PropertyTzname), abbreviation)
+	}
+	return timezone, nil
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// zoneTransitions returns the instants location changes its UTC offset in
+// year, to the second.
+func zoneTransitions(location *time.Location, year int) []time.Time {
+	transitions := []time.Time{}
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); day.Before(end); day = day.AddDate(0, 0, 1) {
+		_, from := day.In(location).Zone()
+		next := day.AddDate(0, 0, 1)
+		if _, to := next.In(location).Zone(); to == from {
+			continue
+		}
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+			middle := low.Add(high.Sub(low) / 2)
+			if _, offset := middle.In(location).Zone(); offset == from {
+				low = middle
+			} else {
+				high = middle
+			}
+		}
+		transitions = append(transitions, high)
+	}
+	return transitions
+}
+
+// nthWeekday returns the nth weekday of month in year, the last one for -1.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	if n < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1))
+}
+
+// formatOffset formats a UTC offset in seconds as ±HHMM.
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+}
@@ -0,0 +1,161 @@
+package vanitycal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlarmDefault is the alarms entry applying to milestone types without
+// their own.
+const AlarmDefault = "default"
+
+// alarmUnits are the units of alarm offsets, e.g. "1w" or "30m".
+var alarmUnits = map[byte]time.Duration{
+	'w': 7 * 24 * time.Hour,
+	'd': 24 * time.Hour,
+	'h': time.Hour,
+	'm': time.Minute,
+}
+
+// parseAlarmOffset parses how long before a milestone an alarm goes off: a
+// number of weeks, days, hours or minutes ("1w", "2d", "1h", "30m"), or "0"
+// for when it starts.
+func parseAlarmOffset(value string) (time.Duration, error) {
+	if value == "0" {
+		return 0, nil
+	}
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid alarm %q, expected e.g. \"1w\", \"1d\", \"1h\", \"30m\" or \"0\"", value)
+	}
+	unit, found := alarmUnits[value[len(value)-1]]
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if !found || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid alarm %q, expected e.g. \"1w\", \"1d\", \"1h\", \"30m\" or \"0\"", value)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// milestoneTypes are the keys of alarms besides AlarmDefault: anniversaries
+// and aggregates by the unit of their label, and the other kinds by name.
+var milestoneTypes = []string{
+	"years", "months", "weeks", "days",
+	KindAnniversary, KindCountdown, KindAggregate, KindCoincidence, KindRecurring,
+	KindNextUp, KindRareDate, KindEnd, KindTodo,
+}
+
+// milestoneType returns the alarms key of event, e.g. "years" for a "10y"
+// anniversary, or its kind.
+func milestoneType(event GeneratedEvent) string {
+	if event.Kind != KindAnniversary && event.Kind != KindAggregate {
+		return event.Kind
+	}
+	if event.Duration == "yearly anniversary" {
+		return "years"
+	}
+	if len(event.Duration) < 2 {
+		return event.Kind
+	}
+	switch event.Duration[len(event.Duration)-1] {
+	case 'y':
+		return "years"
+	case 'm':
+		return "months"
+	case 'w':
+		return "weeks"
+	case 'd':
+		return "days"
+	}
+	return event.Kind // D-DAY
+}
+
+// setEventAlarms sets the alarms of the milestones of event, when it has
+// its own, see eventAlarms.
+func setEventAlarms(events []GeneratedEvent, event Event) {
+	offsets := eventAlarms(event)
+	if offsets == nil {
+		return
+	}
+	for i := range events {
+		events[i].Alarms = []time.Duration{}
+		for _, value := range offsets {
+			if offset, err := parseAlarmOffset(value); err == nil {
+				events[i].Alarms = append(events[i].Alarms, offset)
+			}
+		}
+	}
+}
+
+// setAlarms sets the alarms of the events from the profile of their
+// milestone type. Events from extra_feeds and events with their own alarms
+// keep them.
+func setAlarms(events []GeneratedEvent, config Config) {
+	if len(config.Alarms) == 0 {
+		return
+	}
+	for i, event := range events {
+		if event.Kind == KindExternal || event.Alarms != nil {
+			continue
+		}
+		profile, found := config.Alarms[milestoneType(event)]
+		if !found {
+			profile = config.Alarms[AlarmDefault]
+		}
+		for _, value := range config.AlarmProfiles[profile] {
+			if offset, err := parseAlarmOffset(value); err == nil {
+				events[i].Alarms = append(events[i].Alarms, offset)
+			}
+		}
+	}
+}
+
+// validateAlarms checks the alarm_profiles offsets and that alarms map
+// milestone types to defined profiles.
+func validateAlarms(report func(position, string, ...interface{}), config Config) {
+	profiles := make([]string, 0, len(config.AlarmProfiles))
+	for name := range config.AlarmProfiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		for _, value := range config.AlarmProfiles[name] {
+			if _, err := parseAlarmOffset(value); err != nil {
+				report(position{}, "alarm_profiles.%s: %v", name, err)
+			}
+		}
+	}
+
+	types := make([]string, 0, len(config.Alarms))
+	for milestoneType := range config.Alarms {
+		types = append(types, milestoneType)
+	}
+	sort.Strings(types)
+	for _, milestoneType := range types {
+		if !validAlarmType(milestoneType) {
+			report(position{}, "alarms: unknown milestone type %q, expected one of %s", milestoneType, alarmTypes())
+		}
+		if profile := config.Alarms[milestoneType]; config.AlarmProfiles[profile] == nil {
+			report(position{}, "alarms.%s: unknown alarm profile %q", milestoneType, profile)
+		}
+	}
+}
+
+// validAlarmType reports whether name is a milestone type or AlarmDefault.
+func validAlarmType(name string) bool {
+	if name == AlarmDefault {
+		return true
+	}
+	for _, milestoneType := range milestoneTypes {
+		if name == milestoneType {
+			return true
+		}
+	}
+	return false
+}
+
+// alarmTypes returns the valid keys of alarms, for error messages.
+func alarmTypes() string {
+	return strings.Join(append(append([]string{}, milestoneTypes...), AlarmDefault), ", ")
+}
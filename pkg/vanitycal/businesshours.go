@@ -0,0 +1,87 @@
+package vanitycal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Workweek is when the countdowns of business_hours events count working
+// time, Monday to Friday 09:00-17:00 by default.
+type Workweek struct {
+	// Days are the working days, e.g. ["mon", "tue", "wed", "thu", "fri"].
+	Days []string `toml:"days" yaml:"days" json:"days"`
+	// Hours are the working hours of each day, e.g. "09:00-17:00", in the
+	// calendar timezone.
+	Hours string `toml:"hours" yaml:"hours" json:"hours"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+var defaultWorkweek = Workweek{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: "09:00-17:00"}
+
+// workweek is a parsed Workweek.
+type workweek struct {
+	days       map[time.Weekday]bool
+	open, shut time.Time
+}
+
+func (w Workweek) parse() (workweek, error) {
+	if len(w.Days) == 0 {
+		w.Days = defaultWorkweek.Days
+	}
+	if w.Hours == "" {
+		w.Hours = defaultWorkweek.Hours
+	}
+	week := workweek{days: map[time.Weekday]bool{}}
+	for _, day := range w.Days {
+		weekday, found := weekdayNames[strings.ToLower(day)]
+		if !found {
+			return workweek{}, fmt.Errorf("invalid workweek day %q, expected e.g. \"mon\"", day)
+		}
+		week.days[weekday] = true
+	}
+	open, shut, found := strings.Cut(w.Hours, "-")
+	var err error
+	if found {
+		if week.open, err = time.Parse("15:04", strings.TrimSpace(open)); err == nil {
+			week.shut, err = time.Parse("15:04", strings.TrimSpace(shut))
+		}
+	}
+	if !found || err != nil || !week.open.Before(week.shut) {
+		return workweek{}, fmt.Errorf("invalid workweek hours %q, expected e.g. \"09:00-17:00\"", w.Hours)
+	}
+	return week, nil
+}
+
+// workingHours returns the whole working hours of week between from and to,
+// counted in location.
+func workingHours(from, to time.Time, week workweek, location *time.Location) int {
+	var total time.Duration
+	from, to = from.In(location), to.In(location)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !week.days[day.Weekday()] {
+			continue
+		}
+		open, _ := localTime(day, week.open, location)
+		shut, _ := localTime(day, week.shut, location)
+		if open.Before(from) {
+			open = from
+		}
+		if shut.After(to) {
+			shut = to
+		}
+		if shut.After(open) {
+			total += shut.Sub(open)
+		}
+	}
+	return int(total / time.Hour)
+}
@@ -0,0 +1,933 @@
+package vanitycal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type Event struct {
+	Date string `toml:"date" yaml:"date" json:"date"`
+	// MonthDay ("MM-DD") is used instead of Date when the year is unknown,
+	// e.g. a birthday imported from a vCard "--MMDD" BDAY. Such events only
+	// get a yearly occurrence, without age nor milestones.
+	MonthDay string `toml:"month_day" yaml:"month_day" json:"month_day"`
+	Title    string `toml:"title" yaml:"title" json:"title"`
+	// Description may be a Go template, e.g. "It has been exactly
+	// {{.TotalDays}} days since we met on {{.OriginalDate}}."; see
+	// TemplateData for the available fields.
+	Description string `toml:"description" yaml:"description" json:"description"`
+	// Emoji overrides the calendar-wide emoji, "" for none.
+	Emoji *string `toml:"emoji" yaml:"emoji" json:"emoji"`
+	// SummaryTemplate overrides the calendar-wide summary_template.
+	SummaryTemplate string `toml:"summary_template" yaml:"summary_template" json:"summary_template"`
+	// Alarms are how long before each milestone of the event reminders go
+	// off, e.g. ["1w", "0"]; they override the calendar-wide alarms, [] for
+	// none.
+	Alarms []string `toml:"alarms" yaml:"alarms" json:"alarms"`
+
+	// Profile references a named milestone set defined in
+	// [profiles.<name>].
+	Profile string `toml:"profile" yaml:"profile" json:"profile"`
+	// Patterns references a named set defined in [patterns.<name>].
+	//
+	// Deprecated: use Profile.
+	Patterns string `toml:"patterns" yaml:"patterns" json:"patterns"`
+	// Anniversaries overrides the milestones for this event only.
+	Anniversaries *Anniversary `toml:"anniversaries" yaml:"anniversaries" json:"anniversaries"`
+	// DenseFinalWeek overrides the calendar-wide dense_final_week setting.
+	DenseFinalWeek *bool `toml:"dense_final_week" yaml:"dense_final_week" json:"dense_final_week"`
+	// Journal overrides the calendar-wide journal setting.
+	Journal *bool `toml:"journal" yaml:"journal" json:"journal"`
+	// Since is the date the countdown started (e.g. when the launch was
+	// announced), used to show the progress of countdown entries.
+	Since        string `toml:"since" yaml:"since" json:"since"`
+	ShowProgress bool   `toml:"show_progress" yaml:"show_progress" json:"show_progress"`
+
+	// Source and Confidence document where the date comes from (e.g.
+	// "Google Contacts", "year unknown"); they are shown in descriptions and
+	// emitted as X-VANITYCAL-SOURCE / X-VANITYCAL-CONFIDENCE.
+	Source     string `toml:"source" yaml:"source" json:"source"`
+	Confidence string `toml:"confidence" yaml:"confidence" json:"confidence"`
+
+	// Location, Geo ([latitude, longitude]) and URL are copied to the
+	// milestones, e.g. the restaurant of an anniversary dinner.
+	Location string    `toml:"location" yaml:"location" json:"location"`
+	Geo      []float64 `toml:"geo" yaml:"geo" json:"geo"`
+	URL      string    `toml:"url" yaml:"url" json:"url"`
+
+	// RareDates overrides the calendar-wide rare_dates generators.
+	RareDates []string `toml:"rare_dates" yaml:"rare_dates" json:"rare_dates"`
+
+	// Tags group events, e.g. "family", for next_up entries.
+	Tags []string `toml:"tags" yaml:"tags" json:"tags"`
+	// Categories are written as CATEGORIES, e.g. ["family", "work"], and
+	// can filter the generated calendar, see Config.OnlyCategories.
+	Categories []string `toml:"categories" yaml:"categories" json:"categories"`
+
+	// Until makes the day of the event span several days, e.g. a vacation;
+	// AnniversaryFrom ("start", default, or "end") sets which of date and
+	// until anniversaries are counted from.
+	Until           string `toml:"until" yaml:"until" json:"until"`
+	AnniversaryFrom string `toml:"anniversary_from" yaml:"anniversary_from" json:"anniversary_from"`
+
+	// LeapDayPolicy overrides the calendar-wide leap_day_policy.
+	LeapDayPolicy string `toml:"leap_day_policy" yaml:"leap_day_policy" json:"leap_day_policy"`
+	// Shift overrides the calendar-wide shift rule, "none" disables it.
+	Shift string `toml:"shift" yaml:"shift" json:"shift"`
+
+	// Time ("HH:MM", in the calendar timezone) makes the milestones of the
+	// event timed rather than all-day, lasting Duration (e.g. "2h", default
+	// 1h). A time skipped by a DST change moves forward by the length of the
+	// gap, a repeated one is its first occurrence; validate warns about both.
+	Time     string `toml:"time" yaml:"time" json:"time"`
+	Duration string `toml:"duration" yaml:"duration" json:"duration"`
+	// BusinessHours follows the countdowns of a timed event with the
+	// working hours left until it, e.g. "D-2 · 16 working hours", counted
+	// over the calendar workweek.
+	BusinessHours bool `toml:"business_hours" yaml:"business_hours" json:"business_hours"`
+	// Busy marks the milestones as busy time, for real appointments;
+	// milestones don't block free/busy by default.
+	Busy bool `toml:"busy" yaml:"busy" json:"busy"`
+	// Status ("TENTATIVE", "CONFIRMED" or "CANCELLED") and Class
+	// ("PUBLIC", "PRIVATE" or "CONFIDENTIAL") are written as STATUS and
+	// CLASS, e.g. for a launch date that might slip.
+	Status string `toml:"status" yaml:"status" json:"status"`
+	Class  string `toml:"class" yaml:"class" json:"class"`
+
+	// Organizer and Attendees are e-mail addresses ("mailto:" optional) of
+	// who the event concerns, for shared calendars.
+	Organizer string   `toml:"organizer" yaml:"organizer" json:"organizer"`
+	Attendees []string `toml:"attendees" yaml:"attendees" json:"attendees"`
+
+	// Type "todo" makes the day itself a to-do due that day (VTODO), e.g.
+	// a passport renewal; it only keeps the countdowns besides, as
+	// CountdownOnly. Presets bundle milestones, emoji and summary template
+	// (see Preset): "birthday" also titles yearly milestones with the age,
+	// e.g. "Alice turns 32 🎂", "wedding", "work_anniversary" and "memorial".
+	// Other events are "event" (default).
+	Type string `toml:"type" yaml:"type" json:"type"`
+	// Age is how birthdays show the age: "turns" ("Alice turns 32",
+	// default), "ordinal" ("Alice's 32nd birthday") or "hidden" ("Alice's
+	// birthday").
+	Age string `toml:"age" yaml:"age" json:"age"`
+
+	// CountdownOnly keeps only the countdowns and the day itself, e.g. for
+	// a launch; AnniversaryOnly keeps everything but the countdowns, e.g.
+	// for historical dates. They are mutually exclusive.
+	CountdownOnly   bool `toml:"countdown_only" yaml:"countdown_only" json:"countdown_only"`
+	AnniversaryOnly bool `toml:"anniversary_only" yaml:"anniversary_only" json:"anniversary_only"`
+
+	// EndDate stops the milestones of the event, e.g. when a rental
+	// contract ends; EndEvent adds an "ended" event on that day.
+	EndDate  string `toml:"end_date" yaml:"end_date" json:"end_date"`
+	EndEvent bool   `toml:"end_event" yaml:"end_event" json:"end_event"`
+
+	// ExcludeDates ("YYYY-MM-DD") suppresses the milestones of this event
+	// falling on these days, e.g. a 100-day mark landing on a funeral.
+	ExcludeDates []string `toml:"exclude_dates" yaml:"exclude_dates" json:"exclude_dates"`
+
+	position position
+}
+
+// Anniversary describes which milestones are generated after an event's date.
+type Anniversary struct {
+	Years  []int `toml:"years" yaml:"years" json:"years"`
+	Months []int `toml:"months" yaml:"months" json:"months"`
+	Days   []int `toml:"days" yaml:"days" json:"days"`
+	// Weeks are rendered as week counts, e.g. "1000w".
+	Weeks []int `toml:"weeks" yaml:"weeks" json:"weeks"`
+
+	// Countdowns lists how many days before the date a "D-N" entry is added.
+	Countdowns []int `toml:"countdowns" yaml:"countdowns" json:"countdowns"`
+
+	// Every adds milestones at a fixed interval, instead of listing them.
+	Every []Interval `toml:"every" yaml:"every" json:"every"`
+}
+
+// Interval is a milestone repeated every N units up to Until (inclusive),
+// e.g. every 100 days up to 10000; exactly one unit is set.
+type Interval struct {
+	Years  int `toml:"years" yaml:"years" json:"years"`
+	Months int `toml:"months" yaml:"months" json:"months"`
+	Weeks  int `toml:"weeks" yaml:"weeks" json:"weeks"`
+	Days   int `toml:"days" yaml:"days" json:"days"`
+	Until  int `toml:"until" yaml:"until" json:"until"`
+}
+
+// finalWeekCountdowns are added on top of any pattern when dense_final_week is set.
+var finalWeekCountdowns = []int{3, 2, 1}
+
+var defaultAnniversary = Anniversary{
+	Years:  []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 15, 20, 25, 30, 35, 40, 45, 50},
+	Months: []int{1, 2, 3, 6, 9},
+	Days:   []int{7, 100, 1_000, 10_000},
+}
+
+// Aggregate is a milestone computed over several events, e.g. the combined
+// age of the kids.
+type Aggregate struct {
+	Title       string `toml:"title" yaml:"title" json:"title"`
+	Description string `toml:"description" yaml:"description" json:"description"`
+	// Events lists the titles of the events whose ages are summed.
+	Events []string `toml:"events" yaml:"events" json:"events"`
+	// Years and Days are the combined durations to celebrate.
+	Years []int `toml:"years" yaml:"years" json:"years"`
+	Days  []int `toml:"days" yaml:"days" json:"days"`
+
+	position position
+}
+
+// NextUp is a synthetic event, dated on the day of generation, pointing at
+// the soonest upcoming milestone of the events with one of its tags, e.g.
+// "Next family birthday - Alice 10y in 12 days".
+type NextUp struct {
+	Title       string   `toml:"title" yaml:"title" json:"title"`
+	Description string   `toml:"description" yaml:"description" json:"description"`
+	Tags        []string `toml:"tags" yaml:"tags" json:"tags"`
+
+	position position
+}
+
+type Config struct {
+	// Version is the config format version, see `vanitycal migrate`.
+	Version int `toml:"version" yaml:"version" json:"version"`
+
+	// Includes lists config fragments (glob patterns allowed) merged into
+	// this one; relative paths resolve against the including file.
+	Includes []string `toml:"includes" yaml:"includes" json:"includes"`
+
+	Events     []Event     `toml:"events" yaml:"events" json:"events"`
+	Aggregates []Aggregate `toml:"aggregates" yaml:"aggregates" json:"aggregates"`
+	NextUp     []NextUp    `toml:"next_up" yaml:"next_up" json:"next_up"`
+	// ExtraFeeds are HTTP(S) URLs of external ICS feeds (e.g. public
+	// holidays) whose events are inlined in the generated calendar, so that
+	// subscribers only need one URL.
+	ExtraFeeds []string `toml:"extra_feeds" yaml:"extra_feeds" json:"extra_feeds"`
+	// ExtraEvents is the path of an ICS sidecar (e.g. "extra_events.ics"),
+	// relative to the config file, whose hand-written VEVENTs are copied
+	// untouched into the ICS output. They replace generated events with the
+	// same UID.
+	ExtraEvents string `toml:"extra_events" yaml:"extra_events" json:"extra_events"`
+
+	// Shares are redacted read-only views of the calendar, see Share.
+	Shares []Share `toml:"shares" yaml:"shares" json:"shares"`
+
+	// AlarmProfiles are named sets of reminders, each how long before the
+	// milestone it goes off ("1w", "1d", "1h", "30m" or "0"), e.g.
+	// big = ["1w", "1d", "1h"].
+	AlarmProfiles map[string][]string `toml:"alarm_profiles" yaml:"alarm_profiles" json:"alarm_profiles"`
+	// Alarms maps milestone types to alarm profiles: "years", "months",
+	// "weeks" and "days" for anniversaries by the unit of their label, or a
+	// kind such as "countdown" or "recurring"; "default" covers the others.
+	Alarms map[string]string `toml:"alarms" yaml:"alarms" json:"alarms"`
+
+	// Anniversaries replaces the default milestones for every event without
+	// a type preset.
+	Anniversaries *Anniversary `toml:"anniversaries" yaml:"anniversaries" json:"anniversaries"`
+	// Profiles are named milestone sets that events reference with profile,
+	// instead of repeating the same arrays.
+	Profiles map[string]Anniversary `toml:"profiles" yaml:"profiles" json:"profiles"`
+	// Patterns are named milestone sets that events can reference.
+	//
+	// Deprecated: use Profiles.
+	Patterns map[string]Anniversary `toml:"patterns" yaml:"patterns" json:"patterns"`
+	// DenseFinalWeek adds D-3, D-2 and D-1 countdowns to every event.
+	DenseFinalWeek bool `toml:"dense_final_week" yaml:"dense_final_week" json:"dense_final_week"`
+	// Coincidences enables golden birthdays, palindromic dates and
+	// same-day milestone collisions between events.
+	Coincidences bool `toml:"coincidences" yaml:"coincidences" json:"coincidences"`
+	// AnniversarySeries writes the yearly anniversaries of each event as a
+	// single event with a yearly RRULE (or RDATEs when the years aren't
+	// consecutive), summarized "yearly anniversary", which makes the
+	// calendar much smaller for long year lists.
+	AnniversarySeries bool `toml:"anniversary_series" yaml:"anniversary_series" json:"anniversary_series"`
+	// RoundNumbers adds "nice" milestones to every dated event without
+	// listing them: 1, 2 and 5 times a power of ten from 1000 days and 100
+	// weeks, up to RoundNumbersHorizon years (default 100).
+	RoundNumbers        bool `toml:"round_numbers" yaml:"round_numbers" json:"round_numbers"`
+	RoundNumbersHorizon int  `toml:"round_numbers_horizon" yaml:"round_numbers_horizon" json:"round_numbers_horizon"`
+	// ExpandRecurring writes the previous, current and next occurrences of
+	// yearly events (month_day) instead of a single event with a yearly
+	// RRULE, for clients that ignore recurrence rules.
+	ExpandRecurring bool `toml:"expand_recurring" yaml:"expand_recurring" json:"expand_recurring"`
+	// RareDates enables named generators of rare-date celebrations, e.g.
+	// "leap-day" or "same-weekday", see RareDateGenerators.
+	RareDates []string `toml:"rare_dates" yaml:"rare_dates" json:"rare_dates"`
+	// LeapDayPolicy sets where the yearly anniversaries of February 29 fall
+	// in common years: "mar01" (default) or "feb28". When set, month_day
+	// "02-29" events also recur in common years.
+	LeapDayPolicy string `toml:"leap_day_policy" yaml:"leap_day_policy" json:"leap_day_policy"`
+	// Shift moves anniversaries to the day they are celebrated on:
+	// "next_weekday" moves weekends to Monday, "nearest_saturday" moves any
+	// day to the closest Saturday. Summaries still state the true date.
+	Shift string `toml:"shift" yaml:"shift" json:"shift"`
+	// Journal also writes a VJOURNAL memory entry on each anniversary,
+	// e.g. "5 years since 2020-05-15" followed by the description, which
+	// some clients show as diary notes rather than busy events.
+	Journal bool `toml:"journal" yaml:"journal" json:"journal"`
+
+	// ShowWeekday appends the weekday to summaries of future milestones,
+	// e.g. "10y 💚 (Saturday)", in the configured language.
+	ShowWeekday bool `toml:"show_weekday" yaml:"show_weekday" json:"show_weekday"`
+
+	// Emoji ends the summaries of milestones (default "💚"), "" for none.
+	Emoji *string `toml:"emoji" yaml:"emoji" json:"emoji"`
+	// SummaryTemplate is a Go template replacing the default
+	// "<title> - <duration> <emoji>" summaries, e.g. "{{.Title}} ({{.Duration}})";
+	// see TemplateData for the available fields.
+	SummaryTemplate string `toml:"summary_template" yaml:"summary_template" json:"summary_template"`
+
+	// MaxSummaryLength limits the number of characters (runes) of generated
+	// summaries; the title is trimmed, the duration suffix is always kept.
+	// 0 means no limit.
+	MaxSummaryLength int `toml:"max_summary_length" yaml:"max_summary_length" json:"max_summary_length"`
+
+	// UIDDomain is appended to generated UIDs ("...@cal.example.org"), so
+	// that they are globally unique as RFC 5545 recommends and don't clash
+	// between several vanitycal feeds.
+	UIDDomain string `toml:"uid_domain" yaml:"uid_domain" json:"uid_domain"`
+
+	// CalendarName is the name displayed by clients (defaults to "VanityCal 💚").
+	CalendarName string `toml:"calendar_name" yaml:"calendar_name" json:"calendar_name"`
+	// CalendarDescription is the description displayed by clients.
+	CalendarDescription string `toml:"calendar_description" yaml:"calendar_description" json:"calendar_description"`
+	// ProdID identifies the software that published the feed (defaults to
+	// "-//moul.io//vanitycal <version>//EN").
+	ProdID string `toml:"prodid" yaml:"prodid" json:"prodid"`
+	// Color is how clients display the calendar: a CSS color name such as
+	// "teal", or "#rrggbb".
+	Color string `toml:"color" yaml:"color" json:"color"`
+	// RefreshInterval tells subscribed clients how often to re-fetch the
+	// feed, e.g. "12h".
+	RefreshInterval string `toml:"refresh_interval" yaml:"refresh_interval" json:"refresh_interval"`
+	// Timezone is the calendar timezone (defaults to Europe/Paris).
+	Timezone string `toml:"timezone" yaml:"timezone" json:"timezone"`
+	// Workweek is when business_hours countdowns count working time
+	// (defaults to Monday to Friday, 09:00-17:00).
+	Workweek Workweek `toml:"workweek" yaml:"workweek" json:"workweek"`
+	// ForceUTCAllDay emits all-day events as UTC midnight-to-midnight
+	// date-times instead of DATE values. This is a compatibility option for
+	// clients and pipelines that can't handle floating dates; it ignores the
+	// calendar timezone.
+	ForceUTCAllDay bool `toml:"force_utc_allday" yaml:"force_utc_allday" json:"force_utc_allday"`
+
+	// Language selects how dates and milestone labels are written, e.g.
+	// "fr" for "15 mai 2020", "10 ans" and "J-7" instead of "2020-05-15",
+	// "10y" and "D-7"; see Labels. ISO 8601 dates and English compact
+	// labels are used when unset.
+	Language string `toml:"language" yaml:"language" json:"language"`
+	// Locale is an alias of Language.
+	//
+	// Deprecated: use Language.
+	Locale string `toml:"locale" yaml:"locale" json:"locale"`
+	// CountdownFormat is a Go template replacing the "D-7" countdown
+	// labels, e.g. "T-minus {{.Days}}d" or "J-{{.Days}}"; see CountdownData.
+	CountdownFormat string `toml:"countdown_format" yaml:"countdown_format" json:"countdown_format"`
+	// DurationStyle selects how anniversary durations are written:
+	// "compact" ("1y", default), "verbose" ("1 year and 3 months"), "weeks",
+	// "months" ("15 months") or "total_days" ("456 days").
+	DurationStyle string `toml:"duration_style" yaml:"duration_style" json:"duration_style"`
+
+	// TextEncoding controls how non-ASCII text is emitted: "utf-8" (default)
+	// or "ascii" for legacy clients that garble UTF-8 (accents are
+	// transliterated, emoji are dropped).
+	TextEncoding string `toml:"text_encoding" yaml:"text_encoding" json:"text_encoding"`
+
+	// Clock, when set, replaces the wall clock during generation so that
+	// the output is reproducible. It can't be set from config files.
+	Clock Clock `toml:"-" yaml:"-" json:"-"`
+	// Range, when set, limits the generated events to a window of dates.
+	// It can't be set from config files.
+	Range Range `toml:"-" yaml:"-" json:"-"`
+	// OnlyCategories and ExcludeCategories, when set, keep the events with
+	// one of the former and none of the latter categories, so that one
+	// config can feed several calendars. They can't be set from config
+	// files.
+	OnlyCategories    []string `toml:"-" yaml:"-" json:"-"`
+	ExcludeCategories []string `toml:"-" yaml:"-" json:"-"`
+	// ModTime is the latest modification time of the local files the config
+	// was loaded from, zero for stdin and URLs.
+	ModTime time.Time `toml:"-" yaml:"-" json:"-"`
+
+	// countdownTemplate is CountdownFormat, parsed once by GenerateEvents.
+	countdownTemplate *template.Template `toml:"-" yaml:"-" json:"-"`
+}
+
+// Clock returns the current time.
+type Clock func() time.Time
+
+// Range is a window of days, both bounds included; a zero bound leaves
+// that side open.
+type Range struct {
+	From  time.Time
+	Until time.Time
+}
+
+// Contains tells whether day is within the range.
+func (r Range) Contains(day time.Time) bool {
+	return (r.From.IsZero() || !day.Before(r.From)) && (r.Until.IsZero() || !day.After(r.Until))
+}
+
+// Now returns the time generation runs at: the config clock if set,
+// time.Now otherwise.
+func (c Config) Now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// Today returns the current day in the calendar timezone, as a UTC midnight
+// like milestone dates, so that "today" doesn't depend on the timezone of
+// the machine generating the calendar.
+func (c Config) Today() time.Time {
+	now := c.Now()
+	if location, err := time.LoadLocation(c.timezone()); err == nil {
+		now = now.In(location)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// lastModified is the calendar LAST-MODIFIED: the latest modification of the
+// config files or of the binary generating it, so that unchanged inputs
+// produce identical output. It falls back to Now when the config didn't come
+// from local files.
+func (c Config) lastModified() time.Time {
+	if c.ModTime.IsZero() {
+		return c.Now()
+	}
+	modTime := c.ModTime
+	if executable, err := os.Executable(); err == nil {
+		if info, err := os.Stat(executable); err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime.UTC().Truncate(time.Second)
+}
+
+// Supported config formats, see configFormat.
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// configFormat returns the explicit format if set, otherwise guesses it from
+// the file extension, defaulting to TOML.
+func configFormat(path, format string) (string, error) {
+	switch format {
+	case FormatTOML, FormatYAML, FormatJSON:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("Unsupported config format %q (expected toml, yaml or json)", format)
+	}
+
+	if IsURL(path) {
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return FormatTOML, nil
+	}
+}
+
+// Limits applied when the config is fetched over HTTP(S).
+const (
+	configFetchTimeout = 30 * time.Second
+	maxConfigSize      = 1 << 20 // 1 MiB
+)
+
+// IsURL reports whether the config path is an HTTP(S) URL.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+func fetchConfig(rawURL string) ([]byte, error) {
+	client := &http.Client{Timeout: configFetchTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching config from %s: %s", rawURL, resp.Status)
+	}
+
+	// read one extra byte to detect oversized configs.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching config: %w", err)
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("Error fetching config from %s: larger than %d bytes", rawURL, maxConfigSize)
+	}
+	return data, nil
+}
+
+// LoadConfig decodes the config file at path ('-' for stdin, or an HTTP(S)
+// URL) in the given format, or the one matching its extension when format is
+// empty. Included fragments are merged, the including file taking precedence.
+func LoadConfig(path string, opts LoadOptions) (Config, error) {
+	return loadConfigWithIncludes(path, opts, nil)
+}
+
+// LoadOptions control how config files are decoded and merged.
+type LoadOptions struct {
+	// Format forces the config format instead of guessing it from the extension.
+	Format string
+	// Strict rejects unknown keys instead of silently ignoring them.
+	Strict bool
+	// OnConflict and AllowPartial apply when several files are merged, see
+	// LoadConfigs.
+	OnConflict   string
+	AllowPartial bool
+}
+
+func loadConfigWithIncludes(path string, opts LoadOptions, parents []string) (Config, error) {
+	for _, parent := range parents {
+		if parent == path {
+			return Config{}, fmt.Errorf("Include cycle: %s", strings.Join(append(parents, path), " -> "))
+		}
+	}
+
+	config, err := decodeConfig(path, opts)
+	if err != nil {
+		return Config{}, err
+	}
+	if len(config.Includes) == 0 {
+		return config, nil
+	}
+
+	includes, err := resolveIncludes(path, config.Includes)
+	if err != nil {
+		return Config{}, err
+	}
+	var merged Config
+	for _, include := range includes {
+		fragmentOpts := opts
+		fragmentOpts.Format = "" // fragments are detected by extension
+		fragment, err := loadConfigWithIncludes(include, fragmentOpts, append(parents, path))
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", include, err)
+		}
+		if err := MergeConfig(&merged, fragment, OnConflictOverride); err != nil {
+			return Config{}, err
+		}
+	}
+	config.Includes = nil
+	if err := MergeConfig(&merged, config, OnConflictOverride); err != nil {
+		return Config{}, err
+	}
+	return merged, nil
+}
+
+// resolveIncludes expands the include patterns of the config at path.
+func resolveIncludes(path string, patterns []string) ([]string, error) {
+	resolved := []string{}
+	for _, pattern := range patterns {
+		if IsURL(pattern) {
+			resolved = append(resolved, pattern)
+			continue
+		}
+		if IsURL(path) {
+			base, err := url.Parse(path)
+			if err != nil {
+				return nil, err
+			}
+			ref, err := url.Parse(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid include %q: %w", pattern, err)
+			}
+			resolved = append(resolved, base.ResolveReference(ref).String())
+			continue
+		}
+
+		if !filepath.IsAbs(pattern) && path != "-" {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid include %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("Included file not found: %s", pattern)
+		}
+		resolved = append(resolved, matches...)
+	}
+	return resolved, nil
+}
+
+func decodeConfig(path string, opts LoadOptions) (Config, error) {
+	format, err := configFormat(path, opts.Format)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var data []byte
+	if IsURL(path) {
+		data, err = fetchConfig(path)
+		if err != nil {
+			return Config{}, err
+		}
+	} else if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("Error reading config file: %w", err)
+	}
+
+	var config Config
+	var unknown []string
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &config)
+		if err == nil && opts.Strict {
+			var raw interface{}
+			_ = yaml.Unmarshal(data, &raw)
+			unknown = unknownKeys(raw, reflect.TypeOf(config), "")
+		}
+	case FormatJSON:
+		err = json.Unmarshal(data, &config)
+		if err == nil && opts.Strict {
+			var raw interface{}
+			_ = json.Unmarshal(data, &raw)
+			unknown = unknownKeys(raw, reflect.TypeOf(config), "")
+		}
+	default:
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), &config)
+		if err == nil && opts.Strict {
+			for _, key := range md.Undecoded() {
+				unknown = append(unknown, key.String())
+			}
+		}
+	}
+
+	if err != nil {
+		return Config{}, fmt.Errorf("Error reading config file: %w", err)
+	}
+	if len(unknown) > 0 {
+		return Config{}, fmt.Errorf("Unknown config key(s): %s", strings.Join(unknown, ", "))
+	}
+	if config.Version > CurrentConfigVersion {
+		return Config{}, fmt.Errorf("Config version %d is newer than supported (%d), upgrade vanitycal", config.Version, CurrentConfigVersion)
+	}
+	if err := expandEnv(reflect.ValueOf(&config).Elem()); err != nil {
+		return Config{}, err
+	}
+
+	// remember where entries come from, for validation errors.
+	source := path
+	if path == "-" {
+		source = "<stdin>"
+	}
+	eventLines := tableLines(data, format, "events")
+	for i := range config.Events {
+		config.Events[i].position = position{file: source}
+		if i < len(eventLines) {
+			config.Events[i].position.line = eventLines[i]
+		}
+	}
+	nextUpLines := tableLines(data, format, "next_up")
+	for i := range config.NextUp {
+		config.NextUp[i].position = position{file: source}
+		if i < len(nextUpLines) {
+			config.NextUp[i].position.line = nextUpLines[i]
+		}
+	}
+	aggregateLines := tableLines(data, format, "aggregates")
+	for i := range config.Aggregates {
+		config.Aggregates[i].position = position{file: source}
+		if i < len(aggregateLines) {
+			config.Aggregates[i].position.line = aggregateLines[i]
+		}
+	}
+	if config.ExtraEvents != "" && !filepath.IsAbs(config.ExtraEvents) && path != "-" && !IsURL(path) {
+		config.ExtraEvents = filepath.Join(filepath.Dir(path), config.ExtraEvents)
+	}
+	if path != "-" && !IsURL(path) {
+		if info, err := os.Stat(path); err == nil {
+			config.ModTime = info.ModTime()
+		}
+	}
+	return config, nil
+}
+
+// unknownKeys lists the keys of a generically decoded YAML/JSON document
+// that don't match any field of t.
+func unknownKeys(raw interface{}, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	unknown := []string{}
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			fields[name] = t.Field(i).Type
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldType, found := fields[key]
+			if !found {
+				unknown = append(unknown, prefix+key)
+				continue
+			}
+			unknown = append(unknown, unknownKeys(values[key], fieldType, prefix+key+".")...)
+		}
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			unknown = append(unknown, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i))...)
+		}
+	case reflect.Map:
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for key, value := range values {
+			unknown = append(unknown, unknownKeys(value, t.Elem(), prefix+key+".")...)
+		}
+		sort.Strings(unknown)
+	}
+	return unknown
+}
+
+var envVarPattern = regexp.MustCompile(`\$(\$)?\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// EscapeEnv escapes the ${VAR} references of s so that loading a config
+// keeps them as is instead of expanding environment variables, for text
+// written to configs on behalf of someone else.
+func EscapeEnv(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// expandEnv replaces ${VAR} by the value of the environment variable in
+// every string of the config, so personal data can stay out of committed
+// configs. Referencing an undefined variable is an error. $${VAR} is kept
+// as a literal ${VAR}, see EscapeEnv.
+func expandEnv(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		var missing []string
+		expanded := envVarPattern.ReplaceAllStringFunc(v.String(), func(match string) string {
+			submatches := envVarPattern.FindStringSubmatch(match)
+			if submatches[1] != "" {
+				return match[1:]
+			}
+			name := submatches[2]
+			value, found := os.LookupEnv(name)
+			if !found {
+				missing = append(missing, name)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("Undefined environment variable(s) in config: %s", strings.Join(missing, ", "))
+		}
+		v.SetString(expanded)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandEnv(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := expandEnv(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandEnv(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// map values aren't addressable, expand a copy.
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			if err := expandEnv(value); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+	return nil
+}
+
+// resolvePattern picks the milestones of an event, from the most specific
+// definition to the least: per-event arrays, profile, type preset, global
+// set, built-in defaults.
+func resolvePattern(config Config, event Event) (Anniversary, error) {
+	switch name := eventProfile(event); {
+	case event.Anniversaries != nil:
+		return *event.Anniversaries, nil
+	case name != "":
+		pattern, found := config.profiles()[name]
+		if !found {
+			return Anniversary{}, fmt.Errorf("Unknown profile %q for event %q", name, event.Title)
+		}
+		return pattern, nil
+	}
+	if preset, found := lookupPreset(event); found {
+		return preset.Pattern, nil
+	}
+	switch {
+	case config.Anniversaries != nil:
+		return *config.Anniversaries, nil
+	default:
+		return defaultAnniversary, nil
+	}
+}
+
+// eventProfile returns the profile of event, from profile or the
+// deprecated patterns.
+func eventProfile(event Event) string {
+	if event.Profile != "" {
+		return event.Profile
+	}
+	return event.Patterns
+}
+
+// profiles returns the named milestone sets: profiles, and the deprecated
+// patterns that configs before version 2 define.
+func (c Config) profiles() map[string]Anniversary {
+	if len(c.Patterns) == 0 {
+		return c.Profiles
+	}
+	profiles := make(map[string]Anniversary, len(c.Profiles)+len(c.Patterns))
+	for name, pattern := range c.Patterns {
+		profiles[name] = pattern
+	}
+	for name, profile := range c.Profiles {
+		profiles[name] = profile
+	}
+	return profiles
+}
+
+func denseFinalWeek(config Config, event Event) bool {
+	if event.DenseFinalWeek != nil {
+		return *event.DenseFinalWeek
+	}
+	return config.DenseFinalWeek
+}
+
+// eventEmoji returns the emoji of event, from the most specific definition
+// to the least: its own, its type preset, the calendar's, the default.
+func eventEmoji(config Config, event Event) string {
+	if event.Emoji != nil {
+		return *event.Emoji
+	}
+	if preset, found := lookupPreset(event); found {
+		return preset.Emoji
+	}
+	if config.Emoji != nil {
+		return *config.Emoji
+	}
+	return defaultEmoji
+}
+
+// eventAlarms returns the alarm offsets of event, from its own or its type
+// preset; nil when the calendar-wide alarms apply.
+func eventAlarms(event Event) []string {
+	if event.Alarms != nil {
+		return event.Alarms
+	}
+	if preset, found := lookupPreset(event); found {
+		return preset.Alarms
+	}
+	return nil
+}
+
+func journal(config Config, event Event) bool {
+	if event.Journal != nil {
+		return *event.Journal
+	}
+	return config.Journal
+}
+
+const (
+	defaultTimezone     = "Europe/Paris"
+	defaultCalendarName = "VanityCal 💚"
+	defaultEmoji        = "💚"
+)
+
+// refreshInterval returns the parsed refresh_interval, 0 when unset or
+// invalid (see ValidateConfig).
+func (c Config) refreshInterval() time.Duration {
+	interval, err := time.ParseDuration(c.RefreshInterval)
+	if err != nil {
+		return 0
+	}
+	return interval
+}
+
+func (c Config) prodID() string {
+	if c.ProdID == "" {
+		return "-//moul.io//vanitycal " + Version() + "//EN"
+	}
+	return c.ProdID
+}
+
+// LanguageTag returns the language of the calendar, from language or the
+// deprecated locale.
+func (c Config) LanguageTag() string {
+	if c.Language != "" {
+		return c.Language
+	}
+	return c.Locale
+}
+
+func (c Config) calendarName() string {
+	if c.CalendarName == "" {
+		return defaultCalendarName
+	}
+	return c.CalendarName
+}
+
+func (c Config) timezone() string {
+	if c.Timezone == "" {
+		return defaultTimezone
+	}
+	return c.Timezone
+}
@@ -0,0 +1,30 @@
+// Package vanitycal computes vanity milestones (anniversaries, countdowns,
+// aggregates, coincidences...) from a config and renders them as calendars.
+//
+// The vanitycal command is a thin wrapper around this package; other tools
+// can embed it to generate calendars themselves:
+//
+//	config, err := vanitycal.LoadConfig("vanitycal.toml", vanitycal.LoadOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	return vanitycal.Generate(config, os.Stdout)
+//
+// GenerateEvents exposes the computed milestones without rendering them. The
+// date arithmetic they are built on lives in package datemath, and the
+// ICS/VCS serialization in package render.
+//
+// # API stability
+//
+// The module follows semantic versioning from v1: the exported API of
+// pkg/vanitycal, pkg/datemath and pkg/render only changes in backward
+// compatible ways within a major version. Packages outside pkg/ and the
+// command itself are not part of the API.
+//
+// Identifiers to be removed are first marked with a "Deprecated:" comment
+// naming their replacement, and are kept for at least one minor release;
+// they are only removed in the next major version.
+//
+// New config keys and generated event fields may be added in minor
+// releases; embedders should not rely on the exhaustive list of fields.
+package vanitycal
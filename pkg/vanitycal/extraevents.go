@@ -0,0 +1,25 @@
+package vanitycal
+
+import (
+	"fmt"
+	"os"
+
+	ical "github.com/arran4/golang-ical"
+)
+
+// loadExtraEvents reads the hand-written VEVENTs of the extra_events sidecar.
+func loadExtraEvents(config Config) ([]*ical.VEvent, error) {
+	if config.ExtraEvents == "" {
+		return nil, nil
+	}
+	file, err := os.Open(config.ExtraEvents)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading extra events: %w", err)
+	}
+	defer file.Close()
+	cal, err := ical.ParseCalendar(file)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid extra events %s: %w", config.ExtraEvents, err)
+	}
+	return cal.Events(), nil
+}
@@ -0,0 +1,147 @@
+package vanitycal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+)
+
+// Limits and caching applied to extra_feeds.
+const (
+	feedFetchTimeout = 30 * time.Second
+	maxFeedSize      = 10 << 20 // 10 MiB
+	// feedCacheTTL is how long a fetched feed is reused before fetching it
+	// again; a stale copy is still used when the feed can't be fetched.
+	feedCacheTTL = 6 * time.Hour
+)
+
+// extraFeedEvents fetches the extra feeds and returns their events, so that
+// they are inlined in the generated calendar.
+func extraFeedEvents(config Config) ([]GeneratedEvent, error) {
+	events := []GeneratedEvent{}
+	for _, feedURL := range config.ExtraFeeds {
+		data, err := cachedFeed(feedURL)
+		if err != nil {
+			return nil, err
+		}
+		cal, err := ical.ParseCalendar(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid extra feed %s: %w", feedURL, err)
+		}
+		for _, vevent := range cal.Events() {
+			event, err := feedEvent(vevent, config.TextEncoding)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid extra feed %s: %w", feedURL, err)
+			}
+			event.UID = feedUID(feedURL, event.UID)
+			event.Source = feedURL
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// feedEvent converts a VEVENT of an extra feed into an all-day event on its
+// start date.
+func feedEvent(vevent *ical.VEvent, enc string) (GeneratedEvent, error) {
+	start := vevent.GetProperty(ical.ComponentPropertyDtStart)
+	if start == nil || len(start.Value) < 8 {
+		return GeneratedEvent{}, fmt.Errorf("event %q has no start date", vevent.Id())
+	}
+	// keep the date as written, in the feed's own timezone.
+	date, err := time.Parse("20060102", start.Value[:8])
+	if err != nil {
+		return GeneratedEvent{}, fmt.Errorf("event %q: invalid start date %q", vevent.Id(), start.Value)
+	}
+	event := GeneratedEvent{UID: vevent.Id(), Date: date, Kind: KindExternal}
+	if summary := vevent.GetProperty(ical.ComponentPropertySummary); summary != nil {
+		event.Summary = normalizeText(summary.Value, enc)
+		event.Title = event.Summary
+	}
+	if description := vevent.GetProperty(ical.ComponentPropertyDescription); description != nil {
+		event.Description = normalizeText(description.Value, enc)
+	}
+	return event, nil
+}
+
+// feedUID prefixes the UID of an event of the feed with a hash of its URL,
+// so that it can't collide with the UID of a milestone or of another feed.
+func feedUID(feedURL, uid string) string {
+	sum := sha256.Sum256([]byte(feedURL))
+	return fmt.Sprintf("feed-%x-%s", sum[:8], uid)
+}
+
+// cachedFeed returns the content of the feed, from the user cache directory
+// when it was fetched less than feedCacheTTL ago.
+func cachedFeed(feedURL string) ([]byte, error) {
+	cachePath := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		sum := sha256.Sum256([]byte(feedURL))
+		cachePath = filepath.Join(dir, "vanitycal", "feeds", hex.EncodeToString(sum[:])+".ics")
+	}
+
+	var cached []byte
+	if cachePath != "" {
+		if info, err := os.Stat(cachePath); err == nil {
+			if cached, err = os.ReadFile(cachePath); err != nil {
+				log.Printf("Ignoring cached extra feed %s: %v", feedURL, err)
+				cached = nil
+			}
+			if cached != nil && time.Since(info.ModTime()) < feedCacheTTL {
+				return cached, nil
+			}
+		}
+	}
+
+	data, err := fetchFeed(feedURL)
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	// validate before caching, so a broken response doesn't replace a good copy.
+	if _, err := ical.ParseCalendar(bytes.NewReader(data)); err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, fmt.Errorf("Invalid extra feed %s: %w", feedURL, err)
+	}
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			_ = os.WriteFile(cachePath, data, 0o644)
+		}
+	}
+	return data, nil
+}
+
+func fetchFeed(feedURL string) ([]byte, error) {
+	client := &http.Client{Timeout: feedFetchTimeout}
+	resp, err := client.Get(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching extra feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching extra feed %s: %s", feedURL, resp.Status)
+	}
+
+	// read one extra byte to detect oversized feeds.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching extra feed: %w", err)
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("Error fetching extra feed %s: larger than %d bytes", feedURL, maxFeedSize)
+	}
+	return data, nil
+}
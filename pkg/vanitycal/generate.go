@@ -0,0 +1,1116 @@
+package vanitycal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"moul.io/vanitycal/pkg/datemath"
+)
+
+// GeneratedEvent is a computed milestone, independent of the output format.
+type GeneratedEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	Kind        string
+
+	// Title and Duration are the parts the summary was built from.
+	Title    string
+	Duration string
+
+	// Source and Confidence tell where the source event comes from and how
+	// reliable its date is.
+	Source     string
+	Confidence string
+	// Tags and Categories are those of the source event.
+	Tags       []string
+	Categories []string
+	// Location, Geo and URL are those of the source event.
+	Location string
+	Geo      []float64
+	URL      string
+
+	// Start and End are set for timed events, in the calendar timezone;
+	// Date is then the day of Start.
+	Start time.Time
+	End   time.Time
+	// Until is the last day of all-day events spanning several days.
+	Until time.Time
+	// Busy events block free/busy time.
+	Busy bool
+	// Status and Class are those of the source event, upper-cased.
+	Status string
+	Class  string
+	// Organizer and Attendees are the e-mail addresses of the source
+	// event, without "mailto:".
+	Organizer string
+	Attendees []string
+
+	// RRule is the recurrence rule of the event (e.g. "FREQ=YEARLY"), Date
+	// being its first occurrence; see ExpandRecurring.
+	RRule string
+	// RDates are additional occurrences of the event, and ExDates the
+	// occurrences of the rule to skip.
+	RDates  []time.Time
+	ExDates []time.Time
+
+	// Alarms are how long before the event reminders go off, see
+	// Config.AlarmProfiles.
+	Alarms []time.Duration
+	// Journal is the text of the VJOURNAL memory entry written along with
+	// the event, e.g. "5 years since 2020-05-15", see Config.Journal.
+	Journal string
+
+	// Sequence and Stamp are the SEQUENCE and DTSTAMP of the event, set
+	// from a State.
+	Sequence int
+	Stamp    time.Time
+}
+
+const (
+	KindAnniversary = "anniversary"
+	KindCountdown   = "countdown"
+	KindAggregate   = "aggregate"
+	KindCoincidence = "coincidence"
+	KindRecurring   = "recurring"
+	KindNextUp      = "next-up"
+	KindRareDate    = "rare-date"
+	KindEnd         = "end"
+	KindTodo        = "todo"
+	// KindExternal events come from extra_feeds.
+	KindExternal = "external"
+)
+
+// GenerateEvents computes every milestone of the config.
+func GenerateEvents(config Config) ([]GeneratedEvent, error) {
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	enc := config.TextEncoding
+	today := config.Today()
+	location, err := time.LoadLocation(config.timezone())
+	if err != nil {
+		return nil, err
+	}
+	if config.CountdownFormat != "" {
+		if config.countdownTemplate, err = parseCountdownFormat(config.CountdownFormat); err != nil {
+			return nil, err
+		}
+	}
+	week, err := config.Workweek.parse()
+	if err != nil {
+		return nil, err
+	}
+	calendarTemplate, err := summaryTemplate(config, Event{})
+	if err != nil {
+		return nil, err
+	}
+	generated := []GeneratedEvent{}
+	// summaryErr is the first failure of a summary template, which can only
+	// be detected on execution (e.g. calling a method on a missing field).
+	var summaryErr error
+	summarize := func(tmpl *template.Template, day time.Time, data TemplateData) string {
+		weekday := ""
+		if config.ShowWeekday && day.After(today) {
+			weekday = normalizeText(fmt.Sprintf(" (%s)", FormatWeekday(day, config.LanguageTag())), enc)
+		}
+		if tmpl != nil {
+			var b strings.Builder
+			err := tmpl.Execute(&b, data)
+			if err == nil {
+				return truncateSummary(normalizeText(b.String(), enc), weekday, config.MaxSummaryLength, ellipsisFor(enc))
+			}
+			if summaryErr == nil {
+				summaryErr = fmt.Errorf("%q: %w", data.Title, err)
+			}
+		}
+		suffix := ""
+		if data.Duration != "" {
+			suffix += " - " + data.Duration
+		}
+		if data.Emoji != "" {
+			suffix += " " + data.Emoji
+		}
+		return truncateSummary(data.Title, normalizeText(suffix, enc)+weekday, config.MaxSummaryLength, ellipsisFor(enc))
+	}
+	// milestoneData returns the summary data of a milestone of event on
+	// original; without template, birthdays are titled with the age.
+	milestoneData := func(milestone GeneratedEvent, tmpl *template.Template, original time.Time, event Event) TemplateData {
+		data := templateData(milestone, original, config, event)
+		if title, ok := birthdayTitle(milestone, event, config); ok && tmpl == nil {
+			data.Title, data.Duration = normalizeText(title, enc), ""
+		}
+		return data
+	}
+	// resummarize summarizes again the milestones of event on original (zero
+	// when unknown) with tmpl, the emoji and the type preset of event, when
+	// they differ from the defaults add used.
+	resummarize := func(milestones []GeneratedEvent, tmpl *template.Template, original time.Time, event Event) {
+		if _, found := lookupPreset(event); tmpl == nil && event.Emoji == nil && !found {
+			return
+		}
+		for i, milestone := range milestones {
+			milestones[i].Summary = summarize(tmpl, milestone.Date, milestoneData(milestone, tmpl, original, event))
+		}
+	}
+	add := func(day time.Time, kind, title, duration, description string) {
+		uid := milestoneUID(kind, title, day, duration)
+		if config.UIDDomain != "" {
+			uid += "@" + config.UIDDomain
+		}
+		title = normalizeText(title, enc)
+		generated = append(generated, GeneratedEvent{
+			UID:         uid,
+			Date:        day,
+			Summary:     summarize(nil, day, TemplateData{Title: title, Duration: milestoneLabel(duration, day, config), MilestoneKind: kind, Emoji: eventEmoji(config, Event{})}),
+			Description: normalizeText(description, enc),
+			Kind:        kind,
+			Title:       title,
+			Duration:    duration,
+		})
+	}
+
+	for _, event := range config.Events {
+		tmpl, err := summaryTemplate(config, event)
+		if err != nil {
+			return nil, err
+		}
+		if month, day, yearless, err := eventMonthDay(event); err != nil {
+			return nil, err
+		} else if yearless {
+			start := len(generated)
+			policy := leapDayPolicy(config, event)
+			first := time.Date(recurrenceAnchorYear, month, day, 0, 0, 0, 0, time.UTC)
+			if config.ExpandRecurring {
+				for _, occurrence := range datemath.YearlyOccurrences(month, day, today) {
+					if policy != "" {
+						occurrence = datemath.AddYears(first, occurrence.Year()-recurrenceAnchorYear, policy)
+					}
+					add(occurrence, KindRecurring, event.Title, "", annotateDescription(event))
+				}
+			} else {
+				add(first, KindRecurring, event.Title, "", annotateDescription(event))
+				generated[len(generated)-1].RRule = yearlyRule(first, policy)
+			}
+			generated = append(generated[:start], excludeDates(generated[start:], event)...)
+			resummarize(generated[start:], tmpl, time.Time{}, event)
+			if err := expandDescriptions(generated[start:], event, time.Time{}, config); err != nil {
+				return nil, err
+			}
+			shiftDates(generated[start:], time.Time{}, shiftRule(config, event), func(observed time.Time, milestone GeneratedEvent) string {
+				data := templateData(milestone, time.Time{}, config, event)
+				data.Duration = FormatDate(milestone.Date, config.LanguageTag())
+				return summarize(tmpl, observed, data)
+			})
+			setProvenance(generated[start:], event, enc)
+			setTime(generated[start:], event, location)
+			setEventAlarms(generated[start:], event)
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", event.Date)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing date: %w", err)
+		}
+		pattern, err := eventPattern(config, event)
+		if err != nil {
+			return nil, err
+		}
+		start := len(generated)
+		description := annotateDescription(event)
+		// multi-day events count their anniversaries from their start or end.
+		anchor := date
+		var until time.Time
+		if event.Until != "" {
+			if until, err = time.Parse("2006-01-02", event.Until); err != nil {
+				return nil, fmt.Errorf("Error parsing until date: %w", err)
+			}
+			if event.AnniversaryFrom == AnniversaryFromEnd {
+				anchor = until
+			}
+		}
+
+		policy := leapDayPolicy(config, event)
+		years := pattern.Years
+		if config.AnniversarySeries {
+			years = nil
+		}
+		// yearly anniversaries follow the leap-day policy, in the order of
+		// datemath.Anniversaries.
+		anniversaries := []time.Time{anchor}
+		for _, n := range years {
+			anniversaries = append(anniversaries, datemath.AddYears(anchor, n, policy))
+		}
+		anniversaries = append(anniversaries, datemath.Anniversaries(anchor, nil, pattern.Months, pattern.Days)[1:]...)
+		for _, anniv := range anniversaries {
+			if anniv.Equal(anchor) && event.Type == EventTypeTodo {
+				add(date, KindTodo, event.Title, "due", description)
+				continue
+			}
+			if anniv.Equal(anchor) && !until.IsZero() {
+				add(date, KindAnniversary, event.Title, datemath.FormatDuration(date, date), description)
+				generated[len(generated)-1].Until = until
+				continue
+			}
+			add(anniv, KindAnniversary, event.Title, datemath.FormatDuration(anchor, anniv), description)
+		}
+		for _, anniv := range datemath.WeekAnniversaries(anchor, pattern.Weeks) {
+			add(anniv, KindAnniversary, event.Title, datemath.FormatWeeks(anchor, anniv), description)
+		}
+		if journal(config, event) {
+			for i := start; i < len(generated); i++ {
+				if generated[i].Kind == KindAnniversary && generated[i].RRule == "" && generated[i].Date.After(anchor) {
+					generated[i].Journal = journalText(generated[i].Duration, FormatDate(anchor, config.LanguageTag()), config)
+				}
+			}
+		}
+		if config.AnniversarySeries && len(pattern.Years) > 0 {
+			first, rrule, rdates := yearlySeries(anchor, pattern.Years, policy)
+			add(first, KindAnniversary, event.Title, "yearly anniversary", description)
+			generated[len(generated)-1].RRule = rrule
+			generated[len(generated)-1].RDates = rdates
+		}
+
+		countdownDays := pattern.Countdowns
+		if denseFinalWeek(config, event) && !event.AnniversaryOnly {
+			countdownDays = append(append([]int{}, countdownDays...), finalWeekCountdowns...)
+		}
+		var since time.Time
+		if event.ShowProgress {
+			if event.Since == "" {
+				return nil, fmt.Errorf("Event %q: show_progress requires since", event.Title)
+			}
+			since, err = time.Parse("2006-01-02", event.Since)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing since date: %w", err)
+			}
+		}
+		if config.Coincidences && !countdownOnly(event) {
+			golden := datemath.GoldenBirthday(anchor, policy)
+			add(golden, KindCoincidence, event.Title, fmt.Sprintf(goldenBirthdayLabel, datemath.FormatDuration(anchor, golden)), description)
+			for _, palindrome := range datemath.PalindromeDates(anchor, anchor.AddDate(palindromeHorizonYears, 0, 0)) {
+				add(palindrome, KindCoincidence, event.Title, fmt.Sprintf(palindromeDayLabel, datemath.FormatDuration(anchor, palindrome)), description)
+			}
+		}
+
+		rareDateNames := rareDates(config, event)
+		if countdownOnly(event) {
+			rareDateNames = nil
+		}
+		for _, name := range rareDateNames {
+			generator, found := rareDateGenerators[name]
+			if !found {
+				return nil, fmt.Errorf("Event %q: unknown rare_dates generator %q", event.Title, name)
+			}
+			for _, rare := range generator(anchor) {
+				add(rare.date, KindRareDate, event.Title, rare.label, description)
+			}
+		}
+
+		var clock time.Time
+		if event.BusinessHours {
+			if clock, err = time.Parse("15:04", event.Time); err != nil {
+				return nil, fmt.Errorf("Event %q: business_hours requires time", event.Title)
+			}
+		}
+		for _, countdown := range datemath.Countdowns(date, countdownDays) {
+			duration := datemath.FormatCountdown(countdown, date)
+			if event.ShowProgress {
+				if progress, ok := datemath.Progress(since, countdown, date); ok {
+					duration += fmt.Sprintf(" · %d%% there", progress)
+				}
+			}
+			if event.BusinessHours {
+				from, _ := localTime(countdown, clock, location)
+				deadline, _ := localTime(date, clock, location)
+				duration += fmt.Sprintf(" · %d working hours", workingHours(from, deadline, week, location))
+			}
+			add(countdown, KindCountdown, event.Title, duration, description)
+		}
+
+		if event.EndDate != "" {
+			end, err := time.Parse("2006-01-02", event.EndDate)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing end date: %w", err)
+			}
+			generated = append(generated[:start], endAt(generated[start:], end)...)
+			if event.EndEvent {
+				add(end, KindEnd, event.Title, "ended", description)
+			}
+		}
+		generated = append(generated[:start], excludeDates(generated[start:], event)...)
+		resummarize(generated[start:], tmpl, date, event)
+		if err := expandDescriptions(generated[start:], event, date, config); err != nil {
+			return nil, err
+		}
+		shiftDates(generated[start:], date, shiftRule(config, event), func(observed time.Time, milestone GeneratedEvent) string {
+			data := milestoneData(milestone, tmpl, date, event)
+			if data.Duration == "" {
+				data.Duration = FormatDate(milestone.Date, config.LanguageTag())
+			} else {
+				data.Duration = fmt.Sprintf("%s (%s)", data.Duration, FormatDate(milestone.Date, config.LanguageTag()))
+			}
+			return summarize(tmpl, observed, data)
+		})
+		setProvenance(generated[start:], event, enc)
+		setTime(generated[start:], event, location)
+		setEventAlarms(generated[start:], event)
+	}
+
+	start := len(generated)
+	for _, aggregate := range config.Aggregates {
+		anchors, err := aggregateAnchors(config, aggregate)
+		if err != nil {
+			return nil, err
+		}
+		clamped, _ := clampPattern(Anniversary{Years: aggregate.Years, Days: aggregate.Days})
+		for _, years := range clamped.Years {
+			day := datemath.AggregateDate(anchors, int(math.Round(float64(years)*daysPerYear)))
+			add(day, KindAggregate, aggregate.Title, fmt.Sprintf("%dy", years), aggregate.Description)
+		}
+		for _, days := range clamped.Days {
+			day := datemath.AggregateDate(anchors, days)
+			add(day, KindAggregate, aggregate.Title, fmt.Sprintf("%dd", days), aggregate.Description)
+		}
+	}
+
+	for _, nextUp := range config.NextUp {
+		if target, found := nextTagged(generated, nextUp.Tags, today); found {
+			label := target.Title
+			if target.Duration != "" {
+				label += " " + target.Duration
+			}
+			add(today, KindNextUp, nextUp.Title, fmt.Sprintf("%s %s", label, datemath.RelativeDay(target.Date, today)), nextUp.Description)
+		}
+	}
+
+	if config.Coincidences {
+		for _, collision := range getCollisions(generated) {
+			add(collision.Date, KindCoincidence, collision.Title, collision.Duration, "")
+		}
+	}
+	resummarize(generated[start:], calendarTemplate, time.Time{}, Event{})
+	if summaryErr != nil {
+		return nil, summaryErr
+	}
+
+	if len(config.ExtraFeeds) > 0 {
+		external, err := extraFeedEvents(config)
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, external...)
+	}
+
+	generated = beforeMaxYear(generated)
+	setAlarms(generated, config)
+	if config.Range != (Range{}) {
+		generated = inRange(generated, config.Range)
+	}
+	if len(config.OnlyCategories) > 0 || len(config.ExcludeCategories) > 0 {
+		generated = inCategories(generated, config.OnlyCategories, config.ExcludeCategories)
+	}
+	return generated, nil
+}
+
+// inCategories returns the events with one of the only categories, when
+// set, and none of the excluded ones.
+func inCategories(events []GeneratedEvent, only, exclude []string) []GeneratedEvent {
+	kept := []GeneratedEvent{}
+	for _, event := range events {
+		if (len(only) == 0 || hasAnyTag(event.Categories, only)) && !hasAnyTag(event.Categories, exclude) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// inRange returns the events with an occurrence within r; recurring events
+// are kept whole.
+func inRange(events []GeneratedEvent, r Range) []GeneratedEvent {
+	kept := []GeneratedEvent{}
+	for _, event := range events {
+		if event.RRule == "" && len(event.RDates) == 0 {
+			if r.Contains(event.Date) {
+				kept = append(kept, event)
+			}
+			continue
+		}
+		until := r.Until
+		if until.IsZero() {
+			if !strings.Contains(event.RRule, "COUNT=") && event.RRule != "" {
+				kept = append(kept, event) // endless
+				continue
+			}
+			until = time.Date(maxYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+		}
+		for _, day := range occurrences(event, until) {
+			if r.Contains(day) {
+				kept = append(kept, event)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// maxYear is the last year calendar dates can be written with.
+const maxYear = 9999
+
+// beforeMaxYear drops the events and extra dates after maxYear, which
+// milestones of events close to it reach.
+func beforeMaxYear(events []GeneratedEvent) []GeneratedEvent {
+	kept := []GeneratedEvent{}
+	for _, event := range events {
+		if event.Date.Year() > maxYear {
+			continue
+		}
+		if len(event.RDates) > 0 {
+			rdates := []time.Time{}
+			for _, day := range event.RDates {
+				if day.Year() <= maxYear {
+					rdates = append(rdates, day)
+				}
+			}
+			event.RDates = rdates
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+// AnniversaryFrom values.
+const (
+	AnniversaryFromStart = "start"
+	AnniversaryFromEnd   = "end"
+)
+
+// Event types, see Event.Type.
+const (
+	EventTypeEvent    = "event"
+	EventTypeTodo     = "todo"
+	EventTypeBirthday = "birthday"
+)
+
+// ShiftNone disables the calendar-wide shift rule for an event.
+const ShiftNone = "none"
+
+// recurrenceAnchorYear is the year of the first occurrence of yearly
+// recurring events; a leap year, so that February 29 is valid.
+const recurrenceAnchorYear = 2000
+
+// ExpandRecurring replaces the events with a recurrence rule or additional
+// dates by their occurrences between from and until (inclusive), for
+// consumers that work with single dates. Other events are returned as is.
+func ExpandRecurring(events []GeneratedEvent, from, until time.Time) []GeneratedEvent {
+	expanded := make([]GeneratedEvent, 0, len(events))
+	for _, event := range events {
+		if event.RRule == "" && len(event.RDates) == 0 {
+			expanded = append(expanded, event)
+			continue
+		}
+		excluded := map[time.Time]bool{}
+		for _, day := range event.ExDates {
+			excluded[day] = true
+		}
+		for _, day := range occurrences(event, until) {
+			if day.Before(from) || day.After(until) || excluded[day] {
+				continue
+			}
+			occurrence := event
+			occurrence.Date = day
+			if !event.Start.IsZero() {
+				occurrence.Start = event.Start.AddDate(day.Year()-event.Date.Year(), int(day.Month()-event.Date.Month()), day.Day()-event.Date.Day())
+				occurrence.End = occurrence.Start.Add(event.End.Sub(event.Start))
+			}
+			occurrence.RRule = ""
+			occurrence.RDates = nil
+			occurrence.ExDates = nil
+			expanded = append(expanded, occurrence)
+		}
+	}
+	return expanded
+}
+
+// occurrences returns the dates of the event up to until, for the yearly
+// rules vanitycal generates ("FREQ=YEARLY", optionally with ";COUNT=N" or
+// one of the leapDayRules).
+func occurrences(event GeneratedEvent, until time.Time) []time.Time {
+	days := []time.Time{}
+	if event.RRule != "" {
+		count := -1
+		for _, part := range strings.Split(event.RRule, ";") {
+			if strings.HasPrefix(part, "COUNT=") {
+				count, _ = strconv.Atoi(strings.TrimPrefix(part, "COUNT="))
+			}
+		}
+		for year := event.Date.Year(); year <= until.Year() && count != 0; year++ {
+			day := time.Date(year, event.Date.Month(), event.Date.Day(), 0, 0, 0, 0, time.UTC)
+			switch {
+			case strings.Contains(event.RRule, leapDayRules[datemath.LeapDayMar01]):
+				day = time.Date(year, time.January, 60, 0, 0, 0, 0, time.UTC)
+			case strings.Contains(event.RRule, leapDayRules[datemath.LeapDayFeb28]):
+				day = time.Date(year, time.March, 0, 0, 0, 0, 0, time.UTC)
+			case day.Day() != event.Date.Day():
+				continue // February 29 in a common year
+			}
+			days = append(days, day)
+			count--
+		}
+	} else {
+		days = append(days, event.Date)
+	}
+	return append(days, event.RDates...)
+}
+
+// endAt drops the events after end, and stops recurring ones at end.
+func endAt(events []GeneratedEvent, end time.Time) []GeneratedEvent {
+	kept := events[:0]
+	for _, event := range events {
+		if event.Date.After(end) {
+			continue
+		}
+		var rdates []time.Time
+		for _, day := range event.RDates {
+			if !day.After(end) {
+				rdates = append(rdates, day)
+			}
+		}
+		event.RDates = rdates
+		if event.RRule != "" {
+			rule := event
+			rule.RDates = nil
+			parts := []string{}
+			for _, part := range strings.Split(event.RRule, ";") {
+				if !strings.HasPrefix(part, "COUNT=") {
+					parts = append(parts, part)
+				}
+			}
+			event.RRule = strings.Join(append(parts, fmt.Sprintf("COUNT=%d", len(occurrences(rule, end)))), ";")
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+// excludeDates drops the events falling on the exclude_dates of event, and
+// skips them in recurring ones.
+func excludeDates(events []GeneratedEvent, event Event) []GeneratedEvent {
+	if len(event.ExcludeDates) == 0 {
+		return events
+	}
+	excluded := map[time.Time]bool{}
+	for _, value := range event.ExcludeDates {
+		if day, err := time.Parse("2006-01-02", value); err == nil {
+			excluded[day] = true
+		}
+	}
+	kept := events[:0]
+	for _, generated := range events {
+		if generated.RRule == "" && len(generated.RDates) == 0 {
+			if !excluded[generated.Date] {
+				kept = append(kept, generated)
+			}
+			continue
+		}
+		rdates := []time.Time{}
+		for _, day := range generated.RDates {
+			if !excluded[day] {
+				rdates = append(rdates, day)
+			}
+		}
+		generated.RDates = rdates
+		for _, day := range occurrences(generated, maxExcludedDate(excluded)) {
+			if excluded[day] {
+				generated.ExDates = append(generated.ExDates, day)
+			}
+		}
+		kept = append(kept, generated)
+	}
+	return kept
+}
+
+// maxExcludedDate returns the latest of the excluded days.
+func maxExcludedDate(excluded map[time.Time]bool) time.Time {
+	var latest time.Time
+	for day := range excluded {
+		if day.After(latest) {
+			latest = day
+		}
+	}
+	return latest
+}
+
+// leapDayRules are the yearly RRULE parts of February 29 events recurring in
+// common years, by leap-day policy: the 60th day of the year is February 29
+// or March 1, and the last day of February is February 29 or 28.
+var leapDayRules = map[string]string{
+	datemath.LeapDayMar01: "BYYEARDAY=60",
+	datemath.LeapDayFeb28: "BYMONTH=2;BYMONTHDAY=-1",
+}
+
+// yearlyRule returns the RRULE of a yearly event first occurring on date:
+// February 29 only recurs in leap years unless a leap-day policy is set.
+func yearlyRule(date time.Time, leapDayPolicy string) string {
+	if date.Month() == time.February && date.Day() == 29 && leapDayPolicy != "" {
+		return "FREQ=YEARLY;" + leapDayRules[leapDayPolicy]
+	}
+	return "FREQ=YEARLY"
+}
+
+// leapDayPolicy returns the leap-day policy of event, see
+// datemath.AddYears.
+func leapDayPolicy(config Config, event Event) string {
+	if event.LeapDayPolicy != "" {
+		return event.LeapDayPolicy
+	}
+	return config.LeapDayPolicy
+}
+
+// yearlySeries returns the first yearly anniversary of date among years and
+// either a yearly RRULE when years are consecutive from the first one, or
+// the other anniversaries as RDATEs.
+func yearlySeries(date time.Time, years []int, leapDayPolicy string) (time.Time, string, []time.Time) {
+	sorted := []int{}
+	seen := map[int]bool{}
+	for _, n := range years {
+		if n > 0 && !seen[n] {
+			seen[n] = true
+			sorted = append(sorted, n)
+		}
+	}
+	sort.Ints(sorted)
+	first := datemath.AddYears(date, sorted[0], leapDayPolicy)
+	if sorted[len(sorted)-1]-sorted[0] == len(sorted)-1 && !(date.Month() == time.February && date.Day() == 29) {
+		return first, fmt.Sprintf("FREQ=YEARLY;COUNT=%d", len(sorted)), nil
+	}
+	rdates := []time.Time{}
+	for _, n := range sorted[1:] {
+		rdates = append(rdates, datemath.AddYears(date, n, leapDayPolicy))
+	}
+	return first, "", rdates
+}
+
+// eventMonthDay returns the month and day of events without a known year,
+// set either with month_day or with a vCard-style "--MMDD" date.
+func eventMonthDay(event Event) (time.Month, int, bool, error) {
+	value := event.MonthDay
+	if value == "" && strings.HasPrefix(event.Date, "--") {
+		value = event.Date
+	}
+	if value == "" {
+		return 0, 0, false, nil
+	}
+	if event.MonthDay != "" && event.Date != "" {
+		return 0, 0, false, fmt.Errorf("Event %q: date and month_day are mutually exclusive", event.Title)
+	}
+
+	value = strings.ReplaceAll(strings.TrimPrefix(value, "--"), "-", "")
+	// parse with a leap year so that 02-29 is accepted.
+	parsed, err := time.Parse("20060102", "2000"+value)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("Event %q: invalid month_day %q, expected MM-DD", event.Title, event.MonthDay+event.Date)
+	}
+	return parsed.Month(), parsed.Day(), true, nil
+}
+
+// shiftDates moves the celebrations of an event to their observed date
+// under rule, see datemath.Shift, summarizing them with summarize so that
+// they still state the true date. Countdowns, the event date itself,
+// multi-day and recurring events keep their date.
+func shiftDates(events []GeneratedEvent, date time.Time, rule string, summarize func(observed time.Time, milestone GeneratedEvent) string) {
+	if rule == "" || rule == ShiftNone {
+		return
+	}
+	for i, event := range events {
+		switch event.Kind {
+		case KindAnniversary, KindCoincidence, KindRareDate, KindRecurring:
+		default:
+			continue
+		}
+		if event.Date.Equal(date) || event.RRule != "" || len(event.RDates) > 0 || !event.Until.IsZero() {
+			continue
+		}
+		observed := datemath.Shift(event.Date, rule)
+		if observed.Equal(event.Date) {
+			continue
+		}
+		events[i].Summary = summarize(observed, event)
+		events[i].Date = observed
+	}
+}
+
+// shiftRule returns the shift rule of event.
+func shiftRule(config Config, event Event) string {
+	if event.Shift != "" {
+		return event.Shift
+	}
+	return config.Shift
+}
+
+// defaultEventDuration is how long timed events last when no duration is set.
+const defaultEventDuration = time.Hour
+
+// setTime makes the events timed, at the time of event.
+func setTime(events []GeneratedEvent, event Event, location *time.Location) {
+	if event.Time == "" {
+		return
+	}
+	clock, err := time.Parse("15:04", event.Time)
+	if err != nil {
+		return
+	}
+	duration := defaultEventDuration
+	if event.Duration != "" {
+		if duration, err = time.ParseDuration(event.Duration); err != nil {
+			return
+		}
+	}
+	for i := range events {
+		events[i].Start, _ = localTime(events[i].Date, clock, location)
+		events[i].End = events[i].Start.Add(duration)
+	}
+}
+
+// DST transitions reported by localTime.
+const (
+	dstGap     = "gap"
+	dstOverlap = "overlap"
+)
+
+// localTime returns the instant of the wall clock on day in location,
+// resolving DST transitions as RFC 5545 does: a nonexistent time (in a
+// spring-forward gap) is read with the offset before the gap, so it moves
+// forward by the length of the gap, and an ambiguous time (in a fall-back
+// overlap) is its first occurrence. transition is dstGap, dstOverlap or "".
+func localTime(day, clock time.Time, location *time.Location) (t time.Time, transition string) {
+	wall := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	// the offsets a day apart surround any transition on the day.
+	_, before := wall.Add(-24 * time.Hour).In(location).Zone()
+	_, after := wall.Add(24 * time.Hour).In(location).Zone()
+	valid := []time.Time{}
+	for _, offset := range []int{before, after} {
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(location)
+		if candidate.Hour() == clock.Hour() && candidate.Minute() == clock.Minute() {
+			valid = append(valid, candidate)
+		}
+	}
+	switch {
+	case len(valid) == 0:
+		return wall.Add(-time.Duration(before) * time.Second).In(location), dstGap
+	case len(valid) == 2 && !valid[0].Equal(valid[1]):
+		if valid[1].Before(valid[0]) {
+			return valid[1], dstOverlap
+		}
+		return valid[0], dstOverlap
+	}
+	return valid[0], ""
+}
+
+// setProvenance copies the provenance, tags and place of event to its
+// milestones.
+func setProvenance(events []GeneratedEvent, event Event, enc string) {
+	for i := range events {
+		events[i].Source = normalizeText(event.Source, enc)
+		events[i].Confidence = normalizeText(event.Confidence, enc)
+		events[i].Tags = event.Tags
+		events[i].Categories = event.Categories
+		events[i].Location = normalizeText(event.Location, enc)
+		events[i].Geo = event.Geo
+		events[i].URL = event.URL
+		events[i].Busy = event.Busy
+		events[i].Status = strings.ToUpper(event.Status)
+		events[i].Class = strings.ToUpper(event.Class)
+		events[i].Organizer = strings.TrimPrefix(event.Organizer, "mailto:")
+		events[i].Attendees = nil
+		for _, attendee := range event.Attendees {
+			events[i].Attendees = append(events[i].Attendees, strings.TrimPrefix(attendee, "mailto:"))
+		}
+	}
+}
+
+// annotateDescription appends the provenance of the event to its
+// description, so subscribers know when the age math is a guess.
+func annotateDescription(event Event) string {
+	lines := []string{}
+	if event.Description != "" {
+		lines = append(lines, event.Description)
+	}
+	if event.Source != "" || event.Confidence != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		if event.Source != "" {
+			lines = append(lines, fmt.Sprintf("Source: %s", event.Source))
+		}
+		if event.Confidence != "" {
+			lines = append(lines, fmt.Sprintf("Confidence: %s", event.Confidence))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// milestoneUID derives a UID unique to a milestone and stable across runs
+// from what identifies it: the event title, the kind of milestone, its date
+// and its label (which, with the date, implies the source event's date).
+func milestoneUID(kind, title string, day time.Time, duration string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{kind, title, day.Format("20060102"), duration}, "\x00")))
+	return fmt.Sprintf("vanitycal-%s-%x", day.Format("20060102"), sum[:8])
+}
+
+// nextTagged returns the soonest milestone on or after today of the events
+// with one of the tags.
+func nextTagged(events []GeneratedEvent, tags []string, today time.Time) (GeneratedEvent, bool) {
+	var next GeneratedEvent
+	found := false
+	for _, event := range ExpandRecurring(events, today, today.AddDate(1, 0, 0)) {
+		if event.Date.Before(today) || (found && !event.Date.Before(next.Date)) || !hasAnyTag(event.Tags, tags) {
+			continue
+		}
+		next, found = event, true
+	}
+	return next, found
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LintEvents reports suspicious generated output that doesn't prevent
+// writing the calendar.
+func LintEvents(events []GeneratedEvent) []string {
+	warnings := []string{}
+	seen := map[string]bool{}
+	for _, event := range events {
+		if seen[event.UID] {
+			warnings = append(warnings, fmt.Sprintf("duplicate UID %s (%q)", event.UID, event.Summary))
+		}
+		seen[event.UID] = true
+	}
+	return warnings
+}
+
+// milestoneHorizonYears bounds how far from their event milestones are
+// generated: further ones are nonsensical, and overflow 4-digit years.
+const milestoneHorizonYears = 200
+
+// clampPattern expands the intervals of pattern, drops the values beyond
+// milestoneHorizonYears, and describes them (e.g. "years 500").
+func clampPattern(pattern Anniversary) (Anniversary, []string) {
+	dropped := []string{}
+	maxDays := int(math.Round(milestoneHorizonYears * daysPerYear))
+	limits := map[string]int{
+		"years":  milestoneHorizonYears,
+		"months": milestoneHorizonYears * 12,
+		"weeks":  maxDays / 7,
+		"days":   maxDays,
+	}
+	expanded := map[string][]int{
+		"years":  pattern.Years,
+		"months": pattern.Months,
+		"weeks":  pattern.Weeks,
+		"days":   pattern.Days,
+	}
+	for _, interval := range pattern.Every {
+		unit, step := interval.unit()
+		if step <= 0 {
+			continue
+		}
+		until := interval.Until
+		if until > limits[unit] {
+			dropped = append(dropped, fmt.Sprintf("every %d %s until %d", step, unit, until))
+			until = limits[unit]
+		}
+		seen := map[int]bool{}
+		for _, n := range expanded[unit] {
+			seen[n] = true
+		}
+		values := append([]int{}, expanded[unit]...)
+		for n := step; n <= until; n += step {
+			if !seen[n] {
+				values = append(values, n)
+			}
+		}
+		expanded[unit] = values
+	}
+
+	clamp := func(field string, values []int, limit int) []int {
+		kept := []int{}
+		for _, value := range values {
+			if value > limit {
+				dropped = append(dropped, fmt.Sprintf("%s %d", field, value))
+				continue
+			}
+			kept = append(kept, value)
+		}
+		return kept
+	}
+	clamped := Anniversary{
+		Years:      clamp("years", expanded["years"], limits["years"]),
+		Months:     clamp("months", expanded["months"], limits["months"]),
+		Days:       clamp("days", expanded["days"], limits["days"]),
+		Weeks:      clamp("weeks", expanded["weeks"], limits["weeks"]),
+		Countdowns: clamp("countdowns", pattern.Countdowns, maxDays),
+	}
+	return clamped, dropped
+}
+
+// eventPattern returns the milestones generated after event: its pattern
+// with round numbers, within the horizon, and restricted by countdown_only
+// or anniversary_only.
+func eventPattern(config Config, event Event) (Anniversary, error) {
+	pattern, err := resolvePattern(config, event)
+	if err != nil {
+		return Anniversary{}, err
+	}
+	pattern, _ = clampPattern(withRoundNumbers(config, pattern))
+	switch {
+	case countdownOnly(event):
+		pattern = Anniversary{Countdowns: pattern.Countdowns}
+	case event.AnniversaryOnly:
+		pattern.Countdowns = nil
+	}
+	return pattern, nil
+}
+
+// countdownOnly reports whether event only has countdowns besides its day,
+// as to-dos do.
+func countdownOnly(event Event) bool {
+	return event.CountdownOnly || event.Type == EventTypeTodo
+}
+
+// defaultRoundNumbersHorizon is how far, in years, round_numbers milestones
+// are generated by default.
+const defaultRoundNumbersHorizon = 100
+
+// withRoundNumbers adds the round_numbers milestones to pattern, when
+// enabled, skipping the ones it already lists.
+func withRoundNumbers(config Config, pattern Anniversary) Anniversary {
+	if !config.RoundNumbers {
+		return pattern
+	}
+	horizon := config.RoundNumbersHorizon
+	if horizon == 0 {
+		horizon = defaultRoundNumbersHorizon
+	}
+	merge := func(values []int, min, max int) []int {
+		seen := map[int]bool{}
+		for _, n := range values {
+			seen[n] = true
+		}
+		merged := append([]int{}, values...)
+		for _, n := range datemath.RoundNumbers(min, max) {
+			if !seen[n] {
+				merged = append(merged, n)
+			}
+		}
+		return merged
+	}
+	maxDays := int(math.Round(float64(horizon) * daysPerYear))
+	pattern.Days = merge(pattern.Days, 1000, maxDays)
+	pattern.Weeks = merge(pattern.Weeks, 100, maxDays/7)
+	return pattern
+}
+
+// unit returns the unit and step of the interval, the first one set.
+func (interval Interval) unit() (string, int) {
+	switch {
+	case interval.Years != 0:
+		return "years", interval.Years
+	case interval.Months != 0:
+		return "months", interval.Months
+	case interval.Weeks != 0:
+		return "weeks", interval.Weeks
+	default:
+		return "days", interval.Days
+	}
+}
+
+// palindromeHorizonYears bounds the search for palindromic dates after an anchor.
+const palindromeHorizonYears = 100
+
+// getCollisions finds days on which milestones of different events coincide.
+func getCollisions(events []GeneratedEvent) []GeneratedEvent {
+	byDay := map[string][]GeneratedEvent{}
+	days := []string{}
+	for _, event := range events {
+		if event.Kind != KindAnniversary {
+			continue
+		}
+		key := event.Date.Format("20060102")
+		if _, found := byDay[key]; !found {
+			days = append(days, key)
+		}
+		byDay[key] = append(byDay[key], event)
+	}
+	sort.Strings(days)
+
+	collisions := []GeneratedEvent{}
+	for _, day := range days {
+		seen := map[string]bool{}
+		parts := []string{}
+		for _, event := range byDay[day] {
+			if seen[event.Title] {
+				continue
+			}
+			seen[event.Title] = true
+			parts = append(parts, fmt.Sprintf("%s %s", event.Title, event.Duration))
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		collisions = append(collisions, GeneratedEvent{
+			Date:     byDay[day][0].Date,
+			Title:    strings.Join(parts, " & "),
+			Duration: "same day",
+		})
+	}
+	return collisions
+}
+
+// daysPerYear is the mean length of a gregorian year, used when summing ages.
+const daysPerYear = 365.2425
+
+func aggregateAnchors(config Config, aggregate Aggregate) ([]time.Time, error) {
+	if len(aggregate.Events) == 0 {
+		return nil, fmt.Errorf("Aggregate %q: events is required", aggregate.Title)
+	}
+	anchors := []time.Time{}
+	for _, title := range aggregate.Events {
+		found := false
+		for _, event := range config.Events {
+			if event.Title != title {
+				continue
+			}
+			if _, _, yearless, _ := eventMonthDay(event); yearless {
+				return nil, fmt.Errorf("Aggregate %q: event %q has no year", aggregate.Title, title)
+			}
+			date, err := time.Parse("2006-01-02", event.Date)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing date: %w", err)
+			}
+			anchors = append(anchors, date)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("Aggregate %q: unknown event %q", aggregate.Title, title)
+		}
+	}
+	sort.Slice(anchors, func(i, j int) bool { return anchors[i].Before(anchors[j]) })
+	return anchors, nil
+}
@@ -0,0 +1,131 @@
+package vanitycal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateEventsBeforeMaxYear(t *testing.T) {
+	config := Config{Events: []Event{{
+		Title:         "Late",
+		Date:          "9990-01-01",
+		Anniversaries: &Anniversary{Years: []int{5, 10, 50}, Days: []int{10_000}},
+	}}}
+	events, err := GenerateEvents(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 {
+		t.Fatal("no events generated")
+	}
+	for _, event := range events {
+		if event.Date.Year() > maxYear {
+			t.Errorf("%s on %s is after year %d", event.Summary, event.Date.Format("2006-01-02"), maxYear)
+		}
+	}
+}
+
+func TestValidateConfigNegativeMilestones(t *testing.T) {
+	config := Config{Events: []Event{{
+		Title:         "Negative",
+		Date:          "2020-01-01",
+		Anniversaries: &Anniversary{Years: []int{-5, 0}},
+	}}}
+	err := ValidateConfig(config)
+	if err == nil || !strings.Contains(err.Error(), "years -5") {
+		t.Fatalf("ValidateConfig() = %v, want an error about years -5", err)
+	}
+}
+
+func TestLocalTimeDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	clock, _ := time.Parse("15:04", "02:30")
+	for _, tc := range []struct {
+		day        string
+		want       string
+		transition string
+	}{
+		{"2024-03-30", "2024-03-30T01:30:00Z", ""},
+		{"2024-03-31", "2024-03-31T01:30:00Z", dstGap},
+		{"2024-10-27", "2024-10-27T00:30:00Z", dstOverlap},
+	} {
+		day, _ := time.Parse("2006-01-02", tc.day)
+		got, transition := localTime(day, clock, paris)
+		if got.UTC().Format(time.RFC3339) != tc.want || transition != tc.transition {
+			t.Errorf("localTime(%s) = %s, %q, want %s, %q", tc.day, got.UTC().Format(time.RFC3339), transition, tc.want, tc.transition)
+		}
+	}
+}
+
+func TestLintDST(t *testing.T) {
+	config := Config{Timezone: "Europe/Paris", Events: []Event{{
+		Title:         "Spring forward",
+		Date:          "2023-03-31",
+		Time:          "02:30",
+		Anniversaries: &Anniversary{Years: []int{1}},
+	}}}
+	warnings := LintDST(config)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "2024-03-31 02:30 does not exist") {
+		t.Fatalf("LintDST() = %q, want a DST gap warning on 2024-03-31", warnings)
+	}
+}
+
+func TestGenerateEventsSummaryTemplateError(t *testing.T) {
+	config := Config{Events: []Event{{
+		Title:           "Broken",
+		Date:            "2020-01-01",
+		SummaryTemplate: "{{ .Title.Missing }}",
+	}}}
+	if _, err := GenerateEvents(config); err == nil || !strings.Contains(err.Error(), "summary_template") {
+		t.Fatalf("GenerateEvents() = %v, want a summary_template error", err)
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	week, err := Workweek{Days: []string{"sun", "mon", "tue", "wed", "thu"}, Hours: "08:00-16:30"}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		from, to string
+		want     int
+	}{
+		{"2024-03-28T12:00:00+01:00", "2024-03-28T12:00:00+01:00", 0},
+		// Friday and Saturday are off: 4.5 hours on Thursday, 8.5 on Sunday
+		// and 4 on Monday.
+		{"2024-03-28T12:00:00+01:00", "2024-04-01T12:00:00+02:00", 17},
+		{"2024-03-29T09:00:00+01:00", "2024-03-30T17:00:00+01:00", 0},
+		// partial hours are dropped: 1.5 on Thursday and 1.25 on Sunday.
+		{"2024-03-28T15:00:00+01:00", "2024-03-31T09:15:00+02:00", 2},
+	} {
+		from, _ := time.Parse(time.RFC3339, tc.from)
+		to, _ := time.Parse(time.RFC3339, tc.to)
+		if got := workingHours(from, to, week, paris); got != tc.want {
+			t.Errorf("workingHours(%s, %s) = %d, want %d", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestValidateConfigBusinessHours(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		want   string
+	}{
+		{Config{Events: []Event{{Title: "Launch", Date: "2024-02-02", BusinessHours: true}}}, "business_hours requires time"},
+		{Config{Workweek: Workweek{Days: []string{"monday"}}}, `invalid workweek day "monday"`},
+		{Config{Workweek: Workweek{Hours: "17:00-09:00"}}, `invalid workweek hours "17:00-09:00"`},
+		{Config{Workweek: Workweek{Hours: "9-17"}}, `invalid workweek hours "9-17"`},
+	} {
+		if err := ValidateConfig(tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ValidateConfig(%+v) = %v, want %q", tc.config, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package vanitycal
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of testdata/golden with the current output")
+
+// goldenNow is the deterministic clock of the golden tests.
+var goldenNow = time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+// goldenFormats are the outputs compared for each fixture config, by file
+// extension.
+var goldenFormats = map[string]func(Config, []GeneratedEvent, io.Writer) error{
+	"ics": RenderICal,
+	"vcs": RenderVCS,
+	"pb":  RenderPB,
+}
+
+// TestGolden generates every testdata/golden/*.toml fixture in every format
+// and compares the output to the golden file next to it. Run `go test
+// ./pkg/vanitycal -run TestGolden -update` after an intended output change.
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "golden", "*.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixture in testdata/golden")
+	}
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".toml")
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfig(fixture, LoadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the modification times of the fixture and the test binary
+			// would leak into LAST-MODIFIED, and the build into PRODID.
+			config.ModTime = time.Time{}
+			config.Clock = func() time.Time { return goldenNow }
+			if config.ProdID == "" {
+				config.ProdID = "-//moul.io//vanitycal golden//EN"
+			}
+			events, err := GenerateEvents(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for format, render := range goldenFormats {
+				var got bytes.Buffer
+				if err := render(config, events, &got); err != nil {
+					t.Fatalf("%s: %v", format, err)
+				}
+				golden := filepath.Join("testdata", "golden", name+"."+format)
+				if *update {
+					if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v (run with -update to create it)", err)
+				}
+				if !bytes.Equal(got.Bytes(), want) {
+					t.Errorf("%s differs from %s (run with -update if intended):\n%s", format, golden, got.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package vanitycal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"moul.io/vanitycal/pkg/datemath"
+)
+
+// Labels are the words of milestone labels in a language. Add a language
+// to labelCatalog, or register one with RegisterLabels.
+type Labels struct {
+	// Year, Month, Week and Day are the singular and plural formats of
+	// durations, e.g. {"%d an", "%d ans"}.
+	Year, Month, Week, Day [2]string
+	// Countdown replaces the "D-" of countdowns, e.g. "J-".
+	Countdown string
+	// DDay, Due, Ended and YearlyAnniversary replace the "D-DAY", "due",
+	// "ended" and "yearly anniversary" labels.
+	DDay, Due, Ended, YearlyAnniversary string
+	// Since formats journal entries from a duration and a date, e.g.
+	// "%s depuis le %s".
+	Since string
+	// And joins the last parts of verbose durations, e.g. "et" for
+	// "1 an et 3 mois".
+	And string
+	// Turns, OrdinalBirthday and Birthday title the yearly milestones of
+	// birthdays from the title and the age, e.g. "%s turns %d", "%s's %s
+	// birthday" with the Ordinal of the age, and "%s's birthday".
+	Turns, OrdinalBirthday, Birthday string
+	Ordinal                          func(n int) string
+	// GoldenBirthday, PalindromeDay, FirstLeapDay and LeapDayAnniversary
+	// label coincidences and rare dates from their duration, e.g.
+	// "jour palindrome (%s)"; SameWeekday from the Ordinal of the
+	// occurrence and the duration.
+	GoldenBirthday, PalindromeDay, FirstLeapDay, LeapDayAnniversary, SameWeekday string
+}
+
+// labelCatalog maps languages to their labels. English summaries keep the
+// compact labels ("10y", "D-7"), its entry spells out journal entries.
+var labelCatalog = map[string]Labels{
+	"en": {
+		Year: [2]string{"%d year", "%d years"}, Month: [2]string{"%d month", "%d months"},
+		Week: [2]string{"%d week", "%d weeks"}, Day: [2]string{"%d day", "%d days"},
+		Countdown: "D-", DDay: "D-DAY", Due: "due", Ended: "ended", YearlyAnniversary: "yearly anniversary",
+		Since: "%s since %s", And: "and",
+		Turns: "%s turns %d", OrdinalBirthday: "%s's %s birthday", Birthday: "%s's birthday", Ordinal: datemath.Ordinal,
+		GoldenBirthday: goldenBirthdayLabel, PalindromeDay: palindromeDayLabel, FirstLeapDay: firstLeapDayLabel,
+		LeapDayAnniversary: leapDayAnniversaryLabel, SameWeekday: sameWeekdayLabel,
+	},
+	"fr": {
+		Year: [2]string{"%d an", "%d ans"}, Month: [2]string{"%d mois", "%d mois"},
+		Week: [2]string{"%d semaine", "%d semaines"}, Day: [2]string{"%d jour", "%d jours"},
+		Countdown: "J-", DDay: "Jour J", Due: "échéance", Ended: "terminé", YearlyAnniversary: "anniversaire annuel",
+		Since: "%s depuis le %s", And: "et",
+		Turns: "%s a %d ans", OrdinalBirthday: "%s : %s anniversaire", Birthday: "Anniversaire de %s", Ordinal: frenchOrdinal,
+		GoldenBirthday: "anniversaire d'or (%s)", PalindromeDay: "jour palindrome (%s)", FirstLeapDay: "premier 29 février (%s)",
+		LeapDayAnniversary: "1er anniversaire un 29 février (%s)", SameWeekday: "%s anniversaire le même jour de la semaine (%s)",
+	},
+	"de": {
+		Year: [2]string{"%d Jahr", "%d Jahre"}, Month: [2]string{"%d Monat", "%d Monate"},
+		Week: [2]string{"%d Woche", "%d Wochen"}, Day: [2]string{"%d Tag", "%d Tage"},
+		Countdown: "T-", DDay: "Tag X", Due: "fällig", Ended: "beendet", YearlyAnniversary: "Jahrestag",
+		Since: "%s seit dem %s", And: "und",
+		Turns: "%s wird %d", OrdinalBirthday: "%s: %s Geburtstag", Birthday: "Geburtstag von %s", Ordinal: suffixOrdinal("."),
+		GoldenBirthday: "goldener Geburtstag (%s)", PalindromeDay: "Palindromtag (%s)", FirstLeapDay: "erster Schalttag (%s)",
+		LeapDayAnniversary: "1. Jahrestag an einem Schalttag (%s)", SameWeekday: "%s Jahrestag am selben Wochentag (%s)",
+	},
+	"es": {
+		Year: [2]string{"%d año", "%d años"}, Month: [2]string{"%d mes", "%d meses"},
+		Week: [2]string{"%d semana", "%d semanas"}, Day: [2]string{"%d día", "%d días"},
+		Countdown: "D-", DDay: "Día D", Due: "vence", Ended: "terminado", YearlyAnniversary: "aniversario anual",
+		Since: "%s desde el %s", And: "y",
+		Turns: "%s cumple %d", OrdinalBirthday: "%s: %s cumpleaños", Birthday: "Cumpleaños de %s", Ordinal: suffixOrdinal("º"),
+		GoldenBirthday: "cumpleaños dorado (%s)", PalindromeDay: "día palíndromo (%s)", FirstLeapDay: "primer 29 de febrero (%s)",
+		LeapDayAnniversary: "1.er aniversario en 29 de febrero (%s)", SameWeekday: "%s aniversario en el mismo día de la semana (%s)",
+	},
+	"it": {
+		Year: [2]string{"%d anno", "%d anni"}, Month: [2]string{"%d mese", "%d mesi"},
+		Week: [2]string{"%d settimana", "%d settimane"}, Day: [2]string{"%d giorno", "%d giorni"},
+		Countdown: "G-", DDay: "Giorno X", Due: "scadenza", Ended: "terminato", YearlyAnniversary: "anniversario annuale",
+		Since: "%s dal %s", And: "e",
+		Turns: "%s compie %d anni", OrdinalBirthday: "%s: %s compleanno", Birthday: "Compleanno di %s", Ordinal: suffixOrdinal("°"),
+		GoldenBirthday: "compleanno d'oro (%s)", PalindromeDay: "giorno palindromo (%s)", FirstLeapDay: "primo 29 febbraio (%s)",
+		LeapDayAnniversary: "1° anniversario il 29 febbraio (%s)", SameWeekday: "%s anniversario nello stesso giorno della settimana (%s)",
+	},
+}
+
+// frenchOrdinal formats n as a French ordinal, e.g. "1er" or "32e".
+func frenchOrdinal(n int) string {
+	if n == 1 {
+		return "1er"
+	}
+	return fmt.Sprintf("%de", n)
+}
+
+// suffixOrdinal returns an ordinal format appending suffix to numbers,
+// e.g. "32." in German.
+func suffixOrdinal(suffix string) func(int) string {
+	return func(n int) string { return strconv.Itoa(n) + suffix }
+}
+
+// Duration styles of anniversary labels, e.g. for 15 months.
+const (
+	// DurationStyleCompact is the default, the largest whole unit: "1y".
+	DurationStyleCompact = "compact"
+	// DurationStyleVerbose spells out every unit: "1 year and 3 months".
+	DurationStyleVerbose = "verbose"
+	// DurationStyleWeeks counts weeks and days: "65 weeks and 1 day".
+	DurationStyleWeeks = "weeks"
+	// DurationStyleMonths counts months and days: "15 months".
+	DurationStyleMonths = "months"
+	// DurationStyleTotalDays counts days: "456 days".
+	DurationStyleTotalDays = "total_days"
+)
+
+var durationStyles = []string{DurationStyleCompact, DurationStyleVerbose, DurationStyleWeeks, DurationStyleMonths, DurationStyleTotalDays}
+
+// RegisterLabels adds or replaces the labels of a language. It is meant to
+// be called at init time, before generating calendars.
+func RegisterLabels(language string, labels Labels) {
+	labelCatalog[strings.ToLower(language)] = labels
+}
+
+// lookupLabels finds the labels of a language tag ("fr", "fr-FR"...),
+// falling back to the base language.
+func lookupLabels(language string) (Labels, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	if labels, found := labelCatalog[tag]; found {
+		return labels, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	labels, found := labelCatalog[base]
+	return labels, found
+}
+
+// isEnglish reports whether locale keeps the compact English labels.
+func isEnglish(locale string) bool {
+	base, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(locale, "_", "-")), "-")
+	return base == "" || base == "en"
+}
+
+// CountdownData is what countdown_format can refer to, e.g. "J-{{.Days}}".
+type CountdownData struct {
+	// Days is the number of days left.
+	Days int
+}
+
+// parseCountdownFormat parses a countdown_format and checks that it only
+// refers to CountdownData fields.
+func parseCountdownFormat(text string) (*template.Template, error) {
+	tmpl, err := template.New("countdown_format").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&strings.Builder{}, CountdownData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// milestoneLabel returns how the label of a milestone on day is written in
+// summaries: countdowns follow countdown_format and durations
+// duration_style when set, other labels the locale.
+func milestoneLabel(duration string, day time.Time, config Config) string {
+	language := config.LanguageTag()
+	if config.DurationStyle != "" && config.DurationStyle != DurationStyleCompact {
+		if styled, ok := styleDuration(duration, day, config.DurationStyle, localeLabels(language)); ok {
+			return styled
+		}
+	}
+	if config.CountdownFormat == "" || duration == "D-DAY" || !strings.HasPrefix(duration, "D-") {
+		return localizeDuration(duration, language)
+	}
+	// countdowns may be followed by their progress or working hours.
+	days, rest, _ := strings.Cut(strings.TrimPrefix(duration, "D-"), " ")
+	n, err := strconv.Atoi(days)
+	if err != nil {
+		return localizeDuration(duration, language)
+	}
+	tmpl := config.countdownTemplate
+	if tmpl == nil {
+		if tmpl, err = parseCountdownFormat(config.CountdownFormat); err != nil {
+			return localizeDuration(duration, language)
+		}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, CountdownData{Days: n}); err != nil {
+		return localizeDuration(duration, language)
+	}
+	if rest != "" {
+		b.WriteString(" " + rest)
+	}
+	return b.String()
+}
+
+// localizeDuration translates a milestone label to locale: durations
+// ("10y"), countdowns ("D-7") and the fixed labels. Other labels are kept.
+func localizeDuration(duration, locale string) string {
+	if isEnglish(locale) {
+		return duration
+	}
+	labels, found := lookupLabels(locale)
+	if !found {
+		return duration
+	}
+	return translateDuration(duration, labels)
+}
+
+// translateDuration spells out a milestone label with labels.
+func translateDuration(duration string, labels Labels) string {
+	switch {
+	case duration == "D-DAY":
+		return labels.DDay
+	case duration == "due":
+		return labels.Due
+	case duration == "ended":
+		return labels.Ended
+	case duration == "yearly anniversary":
+		return labels.YearlyAnniversary
+	case strings.HasPrefix(duration, "D-"):
+		// countdowns, possibly followed by their progress or working hours.
+		return labels.Countdown + strings.TrimPrefix(duration, "D-")
+	case len(duration) < 2:
+		return duration
+	}
+	if translated, ok := translateRareLabel(duration, labels); ok {
+		return translated
+	}
+	var forms [2]string
+	switch duration[len(duration)-1] {
+	case 'y':
+		forms = labels.Year
+	case 'm':
+		forms = labels.Month
+	case 'w':
+		forms = labels.Week
+	case 'd':
+		forms = labels.Day
+	default:
+		return duration
+	}
+	n, err := strconv.Atoi(duration[:len(duration)-1])
+	if err != nil || n == 0 {
+		return duration
+	}
+	return spell(forms, n)
+}
+
+// translateRareLabel spells out the label of a coincidence or rare date,
+// e.g. "golden birthday (32y)", with labels. It reports false for other
+// labels, and for labels without these translations.
+func translateRareLabel(duration string, labels Labels) (string, bool) {
+	// the label of an occurrence, e.g. "1st", comes before the duration.
+	inner, found := strings.CutSuffix(duration, ")")
+	if !found {
+		return "", false
+	}
+	for english, translated := range map[string]string{
+		goldenBirthdayLabel:     labels.GoldenBirthday,
+		palindromeDayLabel:      labels.PalindromeDay,
+		firstLeapDayLabel:       labels.FirstLeapDay,
+		leapDayAnniversaryLabel: labels.LeapDayAnniversary,
+	} {
+		prefix := strings.TrimSuffix(english, "%s)")
+		if since, found := strings.CutPrefix(inner, prefix); found && translated != "" {
+			return fmt.Sprintf(translated, translateDuration(since, labels)), true
+		}
+	}
+	_, middle, _ := strings.Cut(strings.TrimSuffix(sameWeekdayLabel, "%s)"), "%s")
+	ordinal, since, found := strings.Cut(inner, middle)
+	digits := strings.TrimRight(ordinal, "abcdefghijklmnopqrstuvwxyz")
+	n, err := strconv.Atoi(digits)
+	if !found || err != nil || labels.SameWeekday == "" || labels.Ordinal == nil {
+		return "", false
+	}
+	return fmt.Sprintf(labels.SameWeekday, labels.Ordinal(n), translateDuration(since, labels)), true
+}
+
+// styleDuration writes a duration label ("10y", "15m", "100w", "456d") of a
+// milestone on day in a duration style. It reports false for other labels.
+func styleDuration(duration string, day time.Time, style string, labels Labels) (string, bool) {
+	if len(duration) < 2 {
+		return "", false
+	}
+	n, err := strconv.Atoi(duration[:len(duration)-1])
+	if err != nil || n <= 0 {
+		return "", false
+	}
+	var start time.Time
+	var years, months, days int
+	switch duration[len(duration)-1] {
+	case 'y':
+		start, years = day.AddDate(-n, 0, 0), n
+	case 'm':
+		start, years, months = day.AddDate(0, -n, 0), n/12, n%12
+	case 'w':
+		start = day.AddDate(0, 0, -7*n)
+		years, months, days = datemath.Elapsed(start, day)
+	case 'd':
+		start = day.AddDate(0, 0, -n)
+		years, months, days = datemath.Elapsed(start, day)
+	default:
+		return "", false
+	}
+	totalDays := int(day.Sub(start).Hours() / 24)
+
+	switch style {
+	case DurationStyleVerbose:
+		return joinParts(labels, spell(labels.Year, years), spell(labels.Month, months), spell(labels.Day, days)), true
+	case DurationStyleMonths:
+		return joinParts(labels, spell(labels.Month, 12*years+months), spell(labels.Day, days)), true
+	case DurationStyleWeeks:
+		return joinParts(labels, spell(labels.Week, totalDays/7), spell(labels.Day, totalDays%7)), true
+	case DurationStyleTotalDays:
+		return spell(labels.Day, totalDays), true
+	}
+	return "", false
+}
+
+// spell writes n with the singular or plural form, "" for 0.
+func spell(forms [2]string, n int) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(forms[0], n)
+	}
+	return fmt.Sprintf(forms[1], n)
+}
+
+// joinParts joins the non-empty parts of a duration, e.g. "1 year, 3
+// months and 2 days".
+func joinParts(labels Labels, parts ...string) string {
+	kept := []string{}
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	if len(kept) <= 1 {
+		return strings.Join(kept, "")
+	}
+	return strings.Join(kept[:len(kept)-1], ", ") + " " + labels.And + " " + kept[len(kept)-1]
+}
+
+// localeLabels returns the labels of locale, English when unset.
+func localeLabels(locale string) Labels {
+	labels, found := lookupLabels(locale)
+	if !found {
+		return labelCatalog["en"]
+	}
+	return labels
+}
+
+// journalText returns the memory entry of a milestone, e.g. "5 years since
+// 2020-05-15", in the configured locale.
+func journalText(duration, original string, config Config) string {
+	labels := localeLabels(config.LanguageTag())
+	return fmt.Sprintf(labels.Since, translateDuration(duration, labels), original)
+}
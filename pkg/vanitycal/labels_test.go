@@ -0,0 +1,41 @@
+package vanitycal
+
+import "testing"
+
+func TestLocaleIsLanguageAlias(t *testing.T) {
+	for _, tt := range []struct {
+		config Config
+		want   string
+	}{
+		{Config{Language: "fr"}, "10 ans"},
+		{Config{Locale: "fr"}, "10 ans"},
+		{Config{Language: "de", Locale: "de"}, "10 Jahre"},
+		{Config{}, "10y"},
+	} {
+		if err := ValidateConfig(tt.config); err != nil {
+			t.Fatalf("%+v: %v", tt.config, err)
+		}
+		if got := milestoneLabel("10y", goldenNow, tt.config); got != tt.want {
+			t.Errorf("%+v: label = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+	if err := ValidateConfig(Config{Language: "fr", Locale: "de"}); err == nil {
+		t.Error("language and locale set differently: want an error")
+	}
+}
+
+func TestTranslateRareLabel(t *testing.T) {
+	for duration, want := range map[string]string{
+		"golden birthday (14y)":               "anniversaire d'or (14 ans)",
+		"palindrome day (537d)":               "jour palindrome (537 jours)",
+		"first leap day (656d)":               "premier 29 février (656 jours)",
+		"1st leap-day anniversary (4y)":       "1er anniversaire un 29 février (4 ans)",
+		"5th same-weekday anniversary (39y)":  "5e anniversaire le même jour de la semaine (39 ans)",
+		"10th same-weekday anniversary (73y)": "10e anniversaire le même jour de la semaine (73 ans)",
+		"same day":                            "same day",
+	} {
+		if got := localizeDuration(duration, "fr"); got != want {
+			t.Errorf("localizeDuration(%q) = %q, want %q", duration, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package vanitycal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLocale describes how humans of a given language read dates.
+type dateLocale struct {
+	months   [12]string
+	weekdays [7]string // starting on Sunday, like time.Weekday
+	// format renders a day with the localized month name.
+	format func(t time.Time, month string) string
+}
+
+var dateLocales = map[string]dateLocale{
+	"en": {
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		format:   func(t time.Time, month string) string { return fmt.Sprintf("%s %d, %d", month, t.Day(), t.Year()) },
+	},
+	"en-gb": {
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		format:   func(t time.Time, month string) string { return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year()) },
+	},
+	"fr": {
+		months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		format: func(t time.Time, month string) string {
+			if t.Day() == 1 {
+				return fmt.Sprintf("1er %s %d", month, t.Year())
+			}
+			return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year())
+		},
+	},
+	"de": {
+		months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		format:   func(t time.Time, month string) string { return fmt.Sprintf("%d. %s %d", t.Day(), month, t.Year()) },
+	},
+	"es": {
+		months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		format:   func(t time.Time, month string) string { return fmt.Sprintf("%d de %s de %d", t.Day(), month, t.Year()) },
+	},
+	"it": {
+		months:   [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		weekdays: [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		format:   func(t time.Time, month string) string { return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year()) },
+	},
+}
+
+// lookupDateLocale finds the locale of a language tag ("fr", "fr-FR",
+// "en_GB"...), falling back to the base language.
+func lookupDateLocale(language string) (dateLocale, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	if locale, found := dateLocales[tag]; found {
+		return locale, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	locale, found := dateLocales[base]
+	return locale, found
+}
+
+func validateLanguage(language string) error {
+	if language == "" {
+		return nil
+	}
+	_, dates := lookupDateLocale(language)
+	if _, labels := lookupLabels(language); !dates && !labels {
+		return fmt.Errorf("unsupported language %q", language)
+	}
+	return nil
+}
+
+// FormatDate renders a date for human-readable outputs, using ISO 8601
+// when no language is configured.
+func FormatDate(t time.Time, language string) string {
+	locale, found := lookupDateLocale(language)
+	if !found {
+		return t.Format("2006-01-02")
+	}
+	return locale.format(t, locale.months[t.Month()-1])
+}
+
+// FormatWeekday returns the weekday name of t in the given language,
+// English by default.
+func FormatWeekday(t time.Time, language string) string {
+	locale, found := lookupDateLocale(language)
+	if !found {
+		return t.Weekday().String()
+	}
+	return locale.weekdays[t.Weekday()]
+}
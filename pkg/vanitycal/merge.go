@@ -0,0 +1,140 @@
+package vanitycal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Conflict policies when several config files set the same setting.
+const (
+	OnConflictOverride = "override"
+	OnConflictError    = "error"
+)
+
+// expandConfigPaths replaces directories by the config files they contain,
+// in lexical order.
+func expandConfigPaths(paths []string) ([]string, error) {
+	expanded := []string{}
+	for _, path := range paths {
+		if path == "-" || IsURL(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// let LoadConfig report unreadable files.
+			expanded = append(expanded, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading config directory: %w", err)
+		}
+		files := []string{}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".toml", ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// LoadConfigs loads and merges several config files. When AllowPartial is
+// set, files that fail to load are skipped and reported in the returned
+// errors instead of failing the whole merge.
+func LoadConfigs(paths []string, opts LoadOptions) (Config, []error, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = OnConflictOverride
+	}
+	if opts.OnConflict != OnConflictOverride && opts.OnConflict != OnConflictError {
+		return Config{}, nil, fmt.Errorf("Invalid on-conflict policy %q, expected %q or %q", opts.OnConflict, OnConflictOverride, OnConflictError)
+	}
+	paths, err := expandConfigPaths(paths)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	if len(paths) == 0 {
+		return Config{}, nil, fmt.Errorf("No config file found")
+	}
+
+	var merged Config
+	var failures []error
+	loaded := 0
+	for _, path := range paths {
+		config, err := LoadConfig(path, opts)
+		if err != nil {
+			if !opts.AllowPartial || len(paths) == 1 {
+				return Config{}, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			failures = append(failures, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if err := MergeConfig(&merged, config, opts.OnConflict); err != nil {
+			return Config{}, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		loaded++
+	}
+	if loaded == 0 {
+		return Config{}, nil, fmt.Errorf("No config file could be loaded: %v", failures)
+	}
+	return merged, failures, nil
+}
+
+// MergeConfig merges src into dst: lists (events, aggregates...) are
+// concatenated, named maps (profiles...) are merged by key, and settings set
+// in both follow the conflict policy.
+func MergeConfig(dst *Config, src Config, onConflict string) error {
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src)
+	configType := dstValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("toml"), ",")[0]
+		d, s := dstValue.Field(i), srcValue.Field(i)
+		switch {
+		case s.IsZero() || name == "-":
+			// runtime fields not set by config files are handled below.
+			continue
+		case d.Kind() == reflect.Slice:
+			d.Set(reflect.AppendSlice(d, s))
+		case d.Kind() == reflect.Map:
+			if d.IsNil() {
+				d.Set(reflect.MakeMap(d.Type()))
+			}
+			iter := s.MapRange()
+			for iter.Next() {
+				if existing := d.MapIndex(iter.Key()); existing.IsValid() && onConflict == OnConflictError &&
+					!reflect.DeepEqual(existing.Interface(), iter.Value().Interface()) {
+					return fmt.Errorf("conflicting %s.%v", name, iter.Key())
+				}
+				d.SetMapIndex(iter.Key(), iter.Value())
+			}
+		default:
+			if !d.IsZero() && onConflict == OnConflictError && !reflect.DeepEqual(d.Interface(), s.Interface()) {
+				return fmt.Errorf("conflicting %s", name)
+			}
+			d.Set(s)
+		}
+	}
+	if src.ModTime.After(dst.ModTime) {
+		dst.ModTime = src.ModTime
+	}
+	if src.Clock != nil {
+		dst.Clock = src.Clock
+	}
+	if src.Range != (Range{}) {
+		dst.Range = src.Range
+	}
+	dst.OnlyCategories = append(dst.OnlyCategories, src.OnlyCategories...)
+	dst.ExcludeCategories = append(dst.ExcludeCategories, src.ExcludeCategories...)
+	return nil
+}
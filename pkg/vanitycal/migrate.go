@@ -0,0 +1,255 @@
+package vanitycal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the config version written by this binary.
+// Configs without a version are version 0.
+const CurrentConfigVersion = 2
+
+// migration upgrades the raw config text from one version to the next. It
+// edits the text rather than re-encoding the config, so that hand-written
+// comments and formatting survive.
+type migration struct {
+	from  int
+	apply func(data []byte, format string) ([]byte, error)
+}
+
+var migrations = []migration{
+	{from: 0, apply: setConfigVersion(1)},
+	// version 2 renames patterns to profiles.
+	{from: 1, apply: chain(renamePatterns, setConfigVersion(2))},
+}
+
+// chain returns a migration step applying steps in order.
+func chain(steps ...func([]byte, string) ([]byte, error)) func([]byte, string) ([]byte, error) {
+	return func(data []byte, format string) ([]byte, error) {
+		var err error
+		for _, step := range steps {
+			if data, err = step(data, format); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+}
+
+// MigrateConfigFile returns the content of the config at path upgraded to
+// the current version, and the version it was at.
+func MigrateConfigFile(path, format string) ([]byte, int, error) {
+	format, err := configFormat(path, format)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	// includes are migrated separately, and migrating only edits the text:
+	// read the version of this file alone, without expanding ${VAR}.
+	from, err := configVersion(data, format)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, m := range migrations {
+		if m.from < from {
+			continue
+		}
+		data, err = m.apply(data, format)
+		if err != nil {
+			return nil, from, fmt.Errorf("migrating from version %d: %w", m.from, err)
+		}
+	}
+	return data, from, nil
+}
+
+// configVersion decodes the version key of a config.
+func configVersion(data []byte, format string) (int, error) {
+	var config struct {
+		Version int `toml:"version" yaml:"version" json:"version"`
+	}
+	var err error
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &config)
+	case FormatJSON:
+		err = json.Unmarshal(data, &config)
+	default:
+		_, err = toml.Decode(string(data), &config)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Error reading config file: %w", err)
+	}
+	if config.Version > CurrentConfigVersion {
+		return 0, fmt.Errorf("Config version %d is newer than supported (%d), upgrade vanitycal", config.Version, CurrentConfigVersion)
+	}
+	return config.Version, nil
+}
+
+// patternsRenames rewrite the deprecated patterns keys, by format: the
+// top-level [patterns] tables become [profiles], and the patterns key of
+// events becomes profile. TOML matches inside strings and comments are
+// skipped, see tomlTextMask.
+var patternsRenames = map[string][]struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	FormatTOML: {
+		{regexp.MustCompile(`(?m)^(\s*\[\s*)patterns(\s*[.\]])`), "${1}profiles${2}"},
+		{regexp.MustCompile(`(?m)^(\s*)patterns(\s*(?:\.|=\s*\{))`), "${1}profiles${2}"},
+		{regexp.MustCompile(`(?m)(^[ \t]*|[{,][ \t]*)patterns([ \t]*=[ \t]*["'])`), "${1}profile${2}"},
+	},
+	FormatYAML: {
+		{regexp.MustCompile(`(?m)^patterns:([ \t]*(?:\{|#|$))`), "profiles:${1}"},
+		{regexp.MustCompile(`(?m)^([ \t]*(?:- )?|.*[{,][ \t]*)patterns:`), "${1}profile:"},
+	},
+	FormatJSON: {
+		{regexp.MustCompile(`"patterns"(\s*:\s*\{)`), `"profiles"${1}`},
+		{regexp.MustCompile(`"patterns"(\s*:\s*")`), `"profile"${1}`},
+	},
+}
+
+// renamePatterns is a migration step replacing the deprecated patterns
+// keys by profiles.
+func renamePatterns(data []byte, format string) ([]byte, error) {
+	for _, rename := range patternsRenames[format] {
+		if format != FormatTOML {
+			data = rename.pattern.ReplaceAll(data, []byte(rename.replacement))
+			continue
+		}
+		mask := tomlTextMask(data)
+		out := []byte{}
+		last := 0
+		for _, match := range rename.pattern.FindAllSubmatchIndex(data, -1) {
+			// the key follows the first group.
+			if mask[match[3]] {
+				continue
+			}
+			out = append(out, data[last:match[0]]...)
+			out = rename.pattern.Expand(out, []byte(rename.replacement), data, match)
+			last = match[1]
+		}
+		data = append(out, data[last:]...)
+	}
+	return data, nil
+}
+
+// tomlTextMask tells which bytes of data are part of a string or a comment,
+// where keys can't be.
+func tomlTextMask(data []byte) []bool {
+	mask := make([]bool, len(data)+1)
+	for i := 0; i < len(data); {
+		end := i
+		switch {
+		case bytes.HasPrefix(data[i:], []byte(`"""`)), bytes.HasPrefix(data[i:], []byte("'''")):
+			delimiter := data[i : i+3]
+			end = i + 3
+			for end < len(data) && !bytes.HasPrefix(data[end:], delimiter) {
+				if delimiter[0] == '"' && data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end += 3
+		case data[i] == '"' || data[i] == '\'':
+			end = i + 1
+			for end < len(data) && data[end] != data[i] && data[end] != '\n' {
+				if data[i] == '"' && data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+		case data[i] == '#':
+			for end < len(data) && data[end] != '\n' {
+				end++
+			}
+		default:
+			i++
+			continue
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		for ; i < end; i++ {
+			mask[i] = true
+		}
+	}
+	return mask
+}
+
+// The version lines may end with a comment, which is kept.
+var (
+	tomlVersionLine = regexp.MustCompile(`(?m)^version\s*=\s*\d+([ \t]*(?:#.*)?)$`)
+	yamlVersionLine = regexp.MustCompile(`(?m)^version\s*:\s*\d+([ \t]*(?:#.*)?)$`)
+	jsonVersionKey  = regexp.MustCompile(`"version"\s*:\s*\d+`)
+)
+
+// setConfigVersion returns a migration step that sets the top-level version
+// key, adding it when missing.
+func setConfigVersion(version int) func([]byte, string) ([]byte, error) {
+	return func(data []byte, format string) ([]byte, error) {
+		switch format {
+		case FormatYAML:
+			line := []byte(fmt.Sprintf("version: %d", version))
+			if yamlVersionLine.Match(data) {
+				return yamlVersionLine.ReplaceAll(data, append(line, "${1}"...)), nil
+			}
+			return append(append(line, '\n'), data...), nil
+		case FormatJSON:
+			key := []byte(fmt.Sprintf(`"version": %d`, version))
+			if jsonVersionKey.Match(data) {
+				return jsonVersionKey.ReplaceAll(data, key), nil
+			}
+			brace := bytes.IndexByte(data, '{')
+			if brace < 0 {
+				return nil, fmt.Errorf("no top-level object")
+			}
+			separator := []byte(",")
+			if len(bytes.TrimSpace(data[brace+1:])) > 0 && bytes.TrimSpace(data[brace+1:])[0] == '}' {
+				separator = nil
+			}
+			out := append([]byte{}, data[:brace+1]...)
+			out = append(out, '\n', ' ', ' ')
+			out = append(out, key...)
+			out = append(out, separator...)
+			return append(out, data[brace+1:]...), nil
+		default:
+			line := []byte(fmt.Sprintf("version = %d", version))
+			if tomlVersionLine.Match(data) {
+				return tomlVersionLine.ReplaceAll(data, append(line, "${1}"...)), nil
+			}
+			// top-level keys must come before the first table, put it after
+			// the leading comments.
+			offset := 0
+			for offset < len(data) {
+				end := bytes.IndexByte(data[offset:], '\n')
+				if end < 0 {
+					end = len(data) - offset
+				} else {
+					end++
+				}
+				trimmed := bytes.TrimSpace(data[offset : offset+end])
+				if len(trimmed) > 0 && trimmed[0] != '#' {
+					break
+				}
+				offset += end
+			}
+			out := append([]byte{}, data[:offset]...)
+			out = append(out, line...)
+			out = append(out, '\n')
+			if len(bytes.TrimSpace(data[offset:])) > 0 {
+				out = append(out, '\n')
+			}
+			return append(out, data[offset:]...), nil
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package vanitycal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigratePatternsToProfiles(t *testing.T) {
+	for name, data := range map[string]string{
+		"config.toml": `# milestones
+version = 1
+
+[patterns.decades]
+years = [10, 20]
+
+[[events]]
+title = "Us"
+date = "2000-01-01"
+patterns = "decades"
+`,
+		"config.yaml": `version: 1
+patterns:
+  decades:
+    years: [10, 20]
+events:
+  - title: Us
+    date: "2000-01-01"
+    patterns: decades
+`,
+		"config.json": `{
+  "version": 1,
+  "patterns": {"decades": {"years": [10, 20]}},
+  "events": [{"title": "Us", "date": "2000-01-01", "patterns": "decades"}]
+}
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			migrated, from, err := MigrateConfigFile(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != 1 {
+				t.Errorf("from = %d, want 1", from)
+			}
+			if err := os.WriteFile(path, migrated, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path, LoadOptions{Strict: true})
+			if err != nil {
+				t.Fatalf("%v\n%s", err, migrated)
+			}
+			if config.Version != CurrentConfigVersion {
+				t.Errorf("version = %d, want %d", config.Version, CurrentConfigVersion)
+			}
+			if len(config.Patterns) != 0 || len(config.Profiles["decades"].Years) != 2 {
+				t.Errorf("patterns = %v, profiles = %v, want the decades profile\n%s", config.Patterns, config.Profiles, migrated)
+			}
+			if len(config.Events) != 1 || config.Events[0].Profile != "decades" || config.Events[0].Patterns != "" {
+				t.Errorf("events = %+v, want a decades profile\n%s", config.Events, migrated)
+			}
+		})
+	}
+}
+
+func TestMigrateCommentedVersion(t *testing.T) {
+	for name, data := range map[string]string{
+		"config.toml": "version = 1 # bumped by hand\n\n[[events]]\ntitle = \"Us\"\ndate = \"2000-01-01\"\n",
+		"config.yaml": "version: 1 # bumped by hand\nevents:\n  - title: Us\n    date: \"2000-01-01\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			migrated, _, err := MigrateConfigFile(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(migrated), "# bumped by hand") || strings.Count(string(migrated), "version") != 1 {
+				t.Errorf("want a single version line keeping its comment\n%s", migrated)
+			}
+			if err := os.WriteFile(path, migrated, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path, LoadOptions{Strict: true})
+			if err != nil {
+				t.Fatalf("%v\n%s", err, migrated)
+			}
+			if config.Version != CurrentConfigVersion {
+				t.Errorf("version = %d, want %d", config.Version, CurrentConfigVersion)
+			}
+		})
+	}
+}
+
+func TestMigrateWithoutEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := "[[events]]\ntitle = \"${VANITYCAL_TEST_UNSET}\"\ndate = \"2000-01-01\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	migrated, from, err := MigrateConfigFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 || !strings.Contains(string(migrated), "${VANITYCAL_TEST_UNSET}") {
+		t.Errorf("from = %d, want 0 and the variable kept\n%s", from, migrated)
+	}
+}
+
+func TestMigratePatternsOnlyRenamesKeys(t *testing.T) {
+	data := `version = 1
+
+[[events]]
+title = "Us"
+date = "2000-01-01"
+description = "set patterns = 'x' to pick a profile"
+notes = """
+patterns = 'x'
+, patterns = "y"
+"""
+# patterns = 'z' used to work
+patterns = "decades"
+extra = { patterns = "decades" }
+`
+	migrated, err := renamePatterns([]byte(data), FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kept := range []string{
+		`description = "set patterns = 'x' to pick a profile"`,
+		"\npatterns = 'x'\n, patterns = \"y\"\n",
+		"# patterns = 'z' used to work",
+	} {
+		if !strings.Contains(string(migrated), kept) {
+			t.Errorf("%q was rewritten\n%s", kept, migrated)
+		}
+	}
+	for _, renamed := range []string{"\nprofile = \"decades\"\n", `{ profile = "decades" }`} {
+		if !strings.Contains(string(migrated), renamed) {
+			t.Errorf("want %q\n%s", renamed, migrated)
+		}
+	}
+}
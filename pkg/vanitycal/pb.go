@@ -0,0 +1,313 @@
+package vanitycal
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// CalendarMeta describes a calendar exchanged with RenderPB and ReadPB.
+type CalendarMeta struct {
+	Name         string
+	Timezone     string
+	LastModified time.Time
+}
+
+// RenderPB writes the events as a Protocol Buffers Calendar message, whose
+// schema is vanitycal.proto, for services exchanging the computed model
+// without parsing ICS.
+func RenderPB(config Config, events []GeneratedEvent, output io.Writer) error {
+	meta := CalendarMeta{
+		Name:         config.calendarName(),
+		Timezone:     config.timezone(),
+		LastModified: config.lastModified(),
+	}
+	var b []byte
+	b = appendMessage(b, 1, appendPBMeta(nil, meta))
+	for _, event := range events {
+		b = appendMessage(b, 2, appendPBEvent(nil, event))
+	}
+	_, err := output.Write(b)
+	return err
+}
+
+// ReadPB reads a calendar written by RenderPB. Unknown fields are skipped,
+// so that readers keep working when fields are added.
+func ReadPB(input io.Reader) (CalendarMeta, []GeneratedEvent, error) {
+	var meta CalendarMeta
+	events := []GeneratedEvent{}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return meta, nil, err
+	}
+	err = consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeFields(value, meta.consumeField)
+		case num == 2 && typ == protowire.BytesType:
+			var event GeneratedEvent
+			if err := consumeFields(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				return consumePBEventField(&event, num, typ, value)
+			}); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return meta, nil, fmt.Errorf("Error reading protobuf calendar: %w", err)
+	}
+	// timed events start in the calendar timezone, the wire only has the
+	// instant.
+	if meta.Timezone != "" {
+		location, err := time.LoadLocation(meta.Timezone)
+		if err != nil {
+			return meta, nil, fmt.Errorf("Error reading protobuf calendar: %w", err)
+		}
+		for i := range events {
+			if !events[i].Start.IsZero() {
+				events[i].Start = events[i].Start.In(location)
+				events[i].End = events[i].End.In(location)
+			}
+		}
+	}
+	return meta, events, nil
+}
+
+func appendPBMeta(b []byte, meta CalendarMeta) []byte {
+	b = appendString(b, 1, meta.Name)
+	b = appendString(b, 2, meta.Timezone)
+	return appendUnix(b, 3, meta.LastModified)
+}
+
+func (meta *CalendarMeta) consumeField(num protowire.Number, typ protowire.Type, value []byte) error {
+	var err error
+	switch num {
+	case 1:
+		meta.Name = string(value)
+	case 2:
+		meta.Timezone = string(value)
+	case 3:
+		meta.LastModified, err = consumeUnix(typ, value)
+	}
+	return err
+}
+
+func appendPBEvent(b []byte, event GeneratedEvent) []byte {
+	b = appendString(b, 1, event.UID)
+	b = appendString(b, 2, event.Date.Format("2006-01-02"))
+	b = appendString(b, 3, event.Summary)
+	b = appendString(b, 4, event.Description)
+	b = appendString(b, 5, event.Kind)
+	b = appendString(b, 6, event.Title)
+	b = appendString(b, 7, event.Duration)
+	b = appendString(b, 8, event.Source)
+	b = appendString(b, 9, event.Confidence)
+	for _, tag := range event.Tags {
+		b = appendMessage(b, 10, []byte(tag))
+	}
+	b = appendString(b, 11, event.RRule)
+	for _, day := range event.RDates {
+		b = appendString(b, 12, day.Format("2006-01-02"))
+	}
+	for _, day := range event.ExDates {
+		b = appendString(b, 13, day.Format("2006-01-02"))
+	}
+	if event.Sequence != 0 {
+		b = protowire.AppendTag(b, 14, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int32(event.Sequence)))
+	}
+	b = appendUnix(b, 15, event.Stamp)
+	b = appendUnix(b, 16, event.Start)
+	b = appendUnix(b, 17, event.End)
+	if !event.Until.IsZero() {
+		b = appendString(b, 18, event.Until.Format("2006-01-02"))
+	}
+	if len(event.Alarms) > 0 {
+		// packed, as proto3 repeated scalars.
+		var packed []byte
+		for _, before := range event.Alarms {
+			packed = protowire.AppendVarint(packed, uint64(int64(before/time.Second)))
+		}
+		b = appendMessage(b, 19, packed)
+	}
+	b = appendString(b, 20, event.Location)
+	if len(event.Geo) > 0 {
+		var packed []byte
+		for _, coordinate := range event.Geo {
+			packed = protowire.AppendFixed64(packed, math.Float64bits(coordinate))
+		}
+		b = appendMessage(b, 21, packed)
+	}
+	b = appendString(b, 22, event.URL)
+	for _, category := range event.Categories {
+		b = appendMessage(b, 23, []byte(category))
+	}
+	if event.Busy {
+		b = protowire.AppendTag(b, 24, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	b = appendString(b, 25, event.Status)
+	b = appendString(b, 26, event.Class)
+	b = appendString(b, 27, event.Organizer)
+	for _, attendee := range event.Attendees {
+		b = appendMessage(b, 28, []byte(attendee))
+	}
+	b = appendString(b, 29, event.Journal)
+	return b
+}
+
+func consumePBEventField(event *GeneratedEvent, num protowire.Number, typ protowire.Type, value []byte) error {
+	var err error
+	var day time.Time
+	switch num {
+	case 1:
+		event.UID = string(value)
+	case 2:
+		event.Date, err = time.Parse("2006-01-02", string(value))
+	case 3:
+		event.Summary = string(value)
+	case 4:
+		event.Description = string(value)
+	case 5:
+		event.Kind = string(value)
+	case 6:
+		event.Title = string(value)
+	case 7:
+		event.Duration = string(value)
+	case 8:
+		event.Source = string(value)
+	case 9:
+		event.Confidence = string(value)
+	case 10:
+		event.Tags = append(event.Tags, string(value))
+	case 11:
+		event.RRule = string(value)
+	case 12:
+		if day, err = time.Parse("2006-01-02", string(value)); err == nil {
+			event.RDates = append(event.RDates, day)
+		}
+	case 13:
+		if day, err = time.Parse("2006-01-02", string(value)); err == nil {
+			event.ExDates = append(event.ExDates, day)
+		}
+	case 14:
+		if typ == protowire.VarintType {
+			n, _ := protowire.ConsumeVarint(value)
+			event.Sequence = int(int32(n))
+		}
+	case 15:
+		event.Stamp, err = consumeUnix(typ, value)
+	case 16:
+		event.Start, err = consumeUnix(typ, value)
+	case 17:
+		event.End, err = consumeUnix(typ, value)
+	case 18:
+		event.Until, err = time.Parse("2006-01-02", string(value))
+	case 19:
+		// packed or not, value is a sequence of varints.
+		for len(value) > 0 {
+			n, size := protowire.ConsumeVarint(value)
+			if size < 0 {
+				return protowire.ParseError(size)
+			}
+			event.Alarms = append(event.Alarms, time.Duration(int64(n))*time.Second)
+			value = value[size:]
+		}
+	case 20:
+		event.Location = string(value)
+	case 21:
+		for len(value) > 0 {
+			n, size := protowire.ConsumeFixed64(value)
+			if size < 0 {
+				return protowire.ParseError(size)
+			}
+			event.Geo = append(event.Geo, math.Float64frombits(n))
+			value = value[size:]
+		}
+	case 22:
+		event.URL = string(value)
+	case 23:
+		event.Categories = append(event.Categories, string(value))
+	case 24:
+		if typ == protowire.VarintType {
+			n, _ := protowire.ConsumeVarint(value)
+			event.Busy = n != 0
+		}
+	case 25:
+		event.Status = string(value)
+	case 26:
+		event.Class = string(value)
+	case 27:
+		event.Organizer = string(value)
+	case 28:
+		event.Attendees = append(event.Attendees, string(value))
+	case 29:
+		event.Journal = string(value)
+	}
+	return err
+}
+
+// appendString appends a string field, omitted when empty as in proto3.
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	return appendMessage(b, num, []byte(value))
+}
+
+func appendMessage(b []byte, num protowire.Number, value []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendUnix(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(t.Unix()))
+}
+
+func consumeUnix(typ protowire.Type, value []byte) (time.Time, error) {
+	if typ != protowire.VarintType {
+		return time.Time{}, fmt.Errorf("expected a varint timestamp")
+	}
+	n, _ := protowire.ConsumeVarint(value)
+	return time.Unix(int64(n), 0).UTC(), nil
+}
+
+// consumeFields calls fn with each field of a message; the value of
+// length-delimited fields is their content, and the raw varint otherwise.
+func consumeFields(b []byte, fn func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var value []byte
+		if typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			value, n = v, m
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			value = b[:n]
+		}
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package vanitycal
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	protoMessage = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	protoField   = regexp.MustCompile(`(?m)^\s*(repeated )?(\w+) (\w+) = (\d+);`)
+	protoScalars = map[string]descriptorpb.FieldDescriptorProto_Type{
+		"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+		"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+		"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+		"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	}
+)
+
+// loadProtoFile builds the descriptor of vanitycal.proto, whose messages
+// only use scalars and messages of the same file.
+func loadProtoFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	source, err := os.ReadFile("vanitycal.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("vanitycal.proto"),
+		Package: proto.String("vanitycal.v1"),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, message := range protoMessage.FindAllStringSubmatch(string(source), -1) {
+		descriptor := &descriptorpb.DescriptorProto{Name: proto.String(message[1])}
+		for _, field := range protoField.FindAllStringSubmatch(message[2], -1) {
+			number, _ := strconv.Atoi(field[4])
+			fd := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(field[3]),
+				JsonName: proto.String(field[3]),
+				Number:   proto.Int32(int32(number)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if field[1] != "" {
+				fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			if scalar, found := protoScalars[field[2]]; found {
+				fd.Type = scalar.Enum()
+			} else {
+				fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				fd.TypeName = proto.String(".vanitycal.v1." + field[2])
+			}
+			descriptor.Field = append(descriptor.Field, fd)
+		}
+		file.MessageType = append(file.MessageType, descriptor)
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// fullPBEvent sets every field of GeneratedEvent.
+func fullPBEvent(t *testing.T) GeneratedEvent {
+	t.Helper()
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2027, time.June, 12, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2027, time.June, 12, 15, 30, 0, 0, paris)
+	return GeneratedEvent{
+		UID:         "uid",
+		Date:        day,
+		Summary:     "summary",
+		Description: "description",
+		Kind:        KindAnniversary,
+		Title:       "title",
+		Duration:    "1y",
+		Source:      "source",
+		Confidence:  "confidence",
+		Tags:        []string{"tag1", "tag2"},
+		Categories:  []string{"category"},
+		Location:    "location",
+		Geo:         []float64{48.85, 2.35},
+		URL:         "https://example.com",
+		Start:       start,
+		End:         start.Add(2 * time.Hour),
+		Until:       day.AddDate(0, 0, 2),
+		Busy:        true,
+		Status:      "CONFIRMED",
+		Class:       "PRIVATE",
+		Organizer:   "organizer@example.com",
+		Attendees:   []string{"attendee@example.com"},
+		RRule:       "FREQ=YEARLY",
+		RDates:      []time.Time{day.AddDate(5, 0, 0)},
+		ExDates:     []time.Time{day.AddDate(1, 0, 0)},
+		Alarms:      []time.Duration{24 * time.Hour, 0},
+		Journal:     "journal",
+		Sequence:    3,
+		Stamp:       time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// TestPBMatchesProto checks that RenderPB and ReadPB use the field numbers
+// and types of vanitycal.proto, and that timed events keep their timezone.
+func TestPBMatchesProto(t *testing.T) {
+	file := loadProtoFile(t)
+	calendarType := file.Messages().ByName("Calendar")
+	config := Config{CalendarName: "name", Timezone: "Europe/Paris", Clock: func() time.Time { return goldenNow }}
+	event := fullPBEvent(t)
+
+	var rendered bytes.Buffer
+	if err := RenderPB(config, []GeneratedEvent{event}, &rendered); err != nil {
+		t.Fatal(err)
+	}
+	calendar := dynamicpb.NewMessage(calendarType)
+	if err := proto.Unmarshal(rendered.Bytes(), calendar); err != nil {
+		t.Fatal(err)
+	}
+	meta := calendar.Get(calendarType.Fields().ByName("meta")).Message()
+	events := calendar.Get(calendarType.Fields().ByName("events")).List()
+	if len(calendar.GetUnknown()) > 0 || len(meta.GetUnknown()) > 0 || events.Len() != 1 {
+		t.Fatalf("RenderPB output doesn't match vanitycal.proto: %v", calendar)
+	}
+	got := events.Get(0).Message()
+	if len(got.GetUnknown()) > 0 {
+		t.Errorf("fields unknown to vanitycal.proto: %v", got.GetUnknown())
+	}
+	fields := got.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if !got.Has(fields.Get(i)) {
+			t.Errorf("field %s of vanitycal.proto isn't written", fields.Get(i).Name())
+		}
+	}
+	for name, want := range map[protoreflect.Name]interface{}{
+		"uid":         event.UID,
+		"date":        "2027-06-12",
+		"summary":     event.Summary,
+		"description": event.Description,
+		"kind":        event.Kind,
+		"title":       event.Title,
+		"duration":    event.Duration,
+		"source":      event.Source,
+		"confidence":  event.Confidence,
+		"rrule":       event.RRule,
+		"sequence":    int32(event.Sequence),
+		"stamp":       event.Stamp.Unix(),
+		"start":       event.Start.Unix(),
+		"end":         event.End.Unix(),
+		"until":       "2027-06-14",
+		"location":    event.Location,
+		"url":         event.URL,
+		"busy":        event.Busy,
+		"status":      event.Status,
+		"class":       event.Class,
+		"organizer":   event.Organizer,
+		"journal":     event.Journal,
+	} {
+		if value := got.Get(fields.ByName(name)).Interface(); value != want {
+			t.Errorf("%s = %v, want %v", name, value, want)
+		}
+	}
+	for name, want := range map[protoreflect.Name][]interface{}{
+		"tags":       {"tag1", "tag2"},
+		"rdates":     {"2032-06-12"},
+		"exdates":    {"2028-06-12"},
+		"alarms":     {int64(86400), int64(0)},
+		"geo":        {48.85, 2.35},
+		"categories": {"category"},
+		"attendees":  {"attendee@example.com"},
+	} {
+		list := got.Get(fields.ByName(name)).List()
+		values := []interface{}{}
+		for i := 0; i < list.Len(); i++ {
+			values = append(values, list.Get(i).Interface())
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("%s = %v, want %v", name, values, want)
+		}
+	}
+
+	// messages encoded from the descriptor read back as the original.
+	encoded, err := proto.Marshal(calendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, data := range map[string][]byte{"RenderPB": rendered.Bytes(), "vanitycal.proto": encoded} {
+		readMeta, readEvents, err := ReadPB(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if readMeta.Name != "name" || readMeta.Timezone != "Europe/Paris" || !readMeta.LastModified.Equal(goldenNow) {
+			t.Errorf("%s: meta = %+v", source, readMeta)
+		}
+		if len(readEvents) != 1 {
+			t.Fatalf("%s: read %d events, want 1", source, len(readEvents))
+		}
+		if !reflect.DeepEqual(readEvents[0], event) {
+			t.Errorf("%s: read\n%+v\nwant\n%+v", source, readEvents[0], event)
+		}
+		if readEvents[0].Start.Location().String() != "Europe/Paris" {
+			t.Errorf("%s: start in %s, want Europe/Paris", source, readEvents[0].Start.Location())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package vanitycal
+
+import "sort"
+
+// Preset is what a type of event or a template bundles, see Event.Type and
+// Template. Events override each part with their own anniversaries, emoji,
+// summary_template and alarms.
+type Preset struct {
+	Pattern         Anniversary
+	Emoji           string
+	SummaryTemplate string
+	// Alarms are alarm offsets, e.g. "1d", see Event.Alarms.
+	Alarms []string
+}
+
+// Event types with a preset.
+const (
+	EventTypeWedding         = "wedding"
+	EventTypeWorkAnniversary = "work_anniversary"
+	EventTypeMemorial        = "memorial"
+)
+
+// presets are the built-in event types besides "event" and "todo".
+var presets = map[string]Preset{
+	EventTypeBirthday: {
+		// birthdays are titled with the age, see birthdayTitle.
+		Pattern: Anniversary{
+			Years: []int{
+				1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20,
+				21, 25, 30, 35, 40, 45, 50, 55, 60, 65, 70, 75, 80, 85, 90, 95, 100,
+			},
+			Days: []int{10_000, 20_000, 30_000},
+		},
+		Emoji: "🎂",
+	},
+	EventTypeWedding: {
+		Pattern: Anniversary{
+			Years:      []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 15, 20, 25, 30, 35, 40, 45, 50, 60},
+			Months:     []int{1, 6},
+			Days:       []int{100, 1_000, 10_000},
+			Countdowns: []int{30, 7},
+		},
+		Emoji: "💍",
+	},
+	EventTypeWorkAnniversary: {
+		Pattern: Anniversary{
+			Years:  []int{1, 2, 3, 5, 10, 15, 20, 25, 30},
+			Months: []int{1, 3, 6},
+			Days:   []int{100, 1_000},
+		},
+		Emoji: "💼",
+	},
+	EventTypeMemorial: {
+		Pattern: Anniversary{
+			Years: []int{1, 2, 3, 5, 10, 15, 20, 25, 30, 40, 50},
+		},
+		Emoji: "🕯️",
+		// the day itself isn't a milestone to count.
+		SummaryTemplate: "In memory of {{.Title}}{{if not .DDay}} - {{.Duration}}{{end}} {{.Emoji}}",
+	},
+}
+
+// eventTypes lists the valid types of events, for error messages.
+func eventTypes() []string {
+	types := []string{EventTypeEvent, EventTypeTodo}
+	for name := range presets {
+		types = append(types, name)
+	}
+	sort.Strings(types[2:])
+	return types
+}
+
+// lookupPreset returns the preset of the type of event.
+func lookupPreset(event Event) (Preset, bool) {
+	preset, found := presets[event.Type]
+	return preset, found
+}
@@ -0,0 +1,75 @@
+package vanitycal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"moul.io/vanitycal/pkg/datemath"
+)
+
+// Labels of coincidences and rare dates, from their duration: generated
+// events carry them in English, summaries translate them with Labels.
+const (
+	goldenBirthdayLabel     = "golden birthday (%s)"
+	palindromeDayLabel      = "palindrome day (%s)"
+	firstLeapDayLabel       = "first leap day (%s)"
+	leapDayAnniversaryLabel = "1st leap-day anniversary (%s)"
+	sameWeekdayLabel        = "%s same-weekday anniversary (%s)"
+)
+
+// rareDate is a celebration found by a rare-date generator.
+type rareDate struct {
+	date  time.Time
+	label string
+}
+
+// sameWeekdayOccurrences are the occurrences celebrated by the same-weekday
+// generator.
+var sameWeekdayOccurrences = []int{1, 5, 10}
+
+// rareDateGenerators are the opt-in generators of rare_dates, by name.
+var rareDateGenerators = map[string]func(anchor time.Time) []rareDate{
+	// leap-day celebrates the first February 29 after the anchor, or the
+	// first real anniversary of anchors on February 29.
+	"leap-day": func(anchor time.Time) []rareDate {
+		leapDay := datemath.NextLeapDay(anchor)
+		label := firstLeapDayLabel
+		if anchor.Month() == time.February && anchor.Day() == 29 {
+			label = leapDayAnniversaryLabel
+		}
+		return []rareDate{{leapDay, fmt.Sprintf(label, datemath.FormatDuration(anchor, leapDay))}}
+	},
+	// same-weekday celebrates the anniversaries falling on the weekday of
+	// the anchor.
+	"same-weekday": func(anchor time.Time) []rareDate {
+		dates := []rareDate{}
+		occurrences := datemath.SameWeekdayAnniversaries(anchor, sameWeekdayOccurrences[len(sameWeekdayOccurrences)-1])
+		for _, n := range sameWeekdayOccurrences {
+			if n <= len(occurrences) {
+				day := occurrences[n-1]
+				dates = append(dates, rareDate{day, fmt.Sprintf(sameWeekdayLabel, datemath.Ordinal(n), datemath.FormatDuration(anchor, day))})
+			}
+		}
+		return dates
+	},
+}
+
+// RareDateGenerators lists the names accepted by rare_dates, sorted.
+func RareDateGenerators() []string {
+	names := make([]string, 0, len(rareDateGenerators))
+	for name := range rareDateGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rareDates returns the generators enabled for the event: its own list if
+// set, the calendar-wide one otherwise.
+func rareDates(config Config, event Event) []string {
+	if event.RareDates != nil {
+		return event.RareDates
+	}
+	return config.RareDates
+}
@@ -0,0 +1,82 @@
+package vanitycal
+
+import (
+	"io"
+
+	"moul.io/vanitycal/pkg/render"
+)
+
+// Generate computes the milestones of the config and writes them to w as an
+// iCalendar 2.0 file.
+func Generate(config Config, w io.Writer) error {
+	events, err := GenerateEvents(config)
+	if err != nil {
+		return err
+	}
+	return RenderICal(config, events, w)
+}
+
+// RenderICal writes the events as an iCalendar 2.0 file.
+func RenderICal(config Config, events []GeneratedEvent, output io.Writer) error {
+	calendar := renderCalendar(config)
+	extra, err := loadExtraEvents(config)
+	if err != nil {
+		return err
+	}
+	calendar.Extra = extra
+	return render.ICal(calendar, renderEvents(events), output)
+}
+
+// RenderVCS writes the events as a vCalendar 1.0 file, for old car systems
+// and feature phones that don't understand iCalendar.
+func RenderVCS(config Config, events []GeneratedEvent, output io.Writer) error {
+	return render.VCS(renderCalendar(config), renderEvents(events), output)
+}
+
+func renderCalendar(config Config) render.Calendar {
+	return render.Calendar{
+		Name:            normalizeText(config.calendarName(), config.TextEncoding),
+		Description:     normalizeText(config.CalendarDescription, config.TextEncoding),
+		ProdID:          config.prodID(),
+		Timezone:        config.timezone(),
+		ForceUTCAllDay:  config.ForceUTCAllDay,
+		LastModified:    config.lastModified(),
+		Color:           config.Color,
+		RefreshInterval: config.refreshInterval(),
+	}
+}
+
+func renderEvents(events []GeneratedEvent) []render.Event {
+	rendered := make([]render.Event, 0, len(events))
+	for _, event := range events {
+		rendered = append(rendered, render.Event{
+			UID:         event.UID,
+			Date:        event.Date,
+			Start:       event.Start,
+			End:         event.End,
+			Until:       event.Until,
+			Busy:        event.Busy,
+			Todo:        event.Kind == KindTodo,
+			Status:      event.Status,
+			Class:       event.Class,
+			Organizer:   event.Organizer,
+			Attendees:   event.Attendees,
+			Summary:     event.Summary,
+			Description: event.Description,
+			Source:      event.Source,
+			Confidence:  event.Confidence,
+			Location:    event.Location,
+			Geo:         event.Geo,
+			URL:         event.URL,
+			Categories:  event.Categories,
+			RRule:       event.RRule,
+			RDates:      event.RDates,
+			ExDates:     event.ExDates,
+			Alarms:      event.Alarms,
+			Journal:     event.Journal,
+			Sequence:    event.Sequence,
+			Stamp:       event.Stamp,
+		})
+	}
+	return rendered
+}
@@ -0,0 +1,67 @@
+package vanitycal
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ConfigSchema returns a JSON Schema of the config, derived from the Go
+// types so that it never drifts from them.
+func ConfigSchema() map[string]interface{} {
+	defs := map[string]interface{}{}
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "https://moul.io/vanitycal/config.schema.json",
+		"title":   "vanitycal config",
+	}
+	for key, value := range typeSchema(reflect.TypeOf(Config{}), defs, true) {
+		schema[key] = value
+	}
+	schema["$defs"] = defs
+	return schema
+}
+
+// typeSchema returns the schema of t; named structs other than the root are
+// added to defs and referenced.
+func typeSchema(t reflect.Type, defs map[string]interface{}, root bool) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs, root)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), defs, false)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs, false)}
+	case reflect.Struct:
+		if !root {
+			if _, found := defs[t.Name()]; !found {
+				defs[t.Name()] = nil // placeholder against recursive types
+				defs[t.Name()] = typeSchema(t, defs, true)
+			}
+			return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+		}
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			properties[name] = typeSchema(field.Type, defs, false)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
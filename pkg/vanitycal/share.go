@@ -0,0 +1,61 @@
+package vanitycal
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Share is a read-only view of the calendar, served by `vanitycal serve` at
+// /share/<token>.ics, that can hide personal details from the people it is
+// shared with.
+type Share struct {
+	Name string `toml:"name" yaml:"name" json:"name"`
+	// Token is the secret part of the share URL; use ${VAR} to keep it out
+	// of the config file.
+	Token string `toml:"token" yaml:"token" json:"token"`
+	// Tags restricts the view to events with one of these tags.
+	Tags []string `toml:"tags" yaml:"tags" json:"tags"`
+	// Label replaces every summary (e.g. "Busy 🎉"), drops descriptions,
+	// places, people, provenance, tags and categories, and hashes the UIDs.
+	// Titles are kept when empty.
+	Label string `toml:"label" yaml:"label" json:"label"`
+}
+
+// minShareTokenLength keeps share URLs hard to guess.
+const minShareTokenLength = 16
+
+// Apply returns the events visible through the share, redacted.
+func (s Share) Apply(events []GeneratedEvent) []GeneratedEvent {
+	shared := []GeneratedEvent{}
+	for _, event := range events {
+		if len(s.Tags) > 0 && !hasAnyTag(event.Tags, s.Tags) {
+			continue
+		}
+		if s.Label != "" {
+			event.Summary = s.Label
+			event.Title = s.Label
+			event.Duration = ""
+			event.Description = ""
+			event.Source = ""
+			event.Confidence = ""
+			event.Location = ""
+			event.Geo = nil
+			event.URL = ""
+			event.Organizer = ""
+			event.Attendees = nil
+			event.Journal = ""
+			event.Tags = nil
+			event.Categories = nil
+			event.UID = redactedUID(event.UID)
+		}
+		shared = append(shared, event)
+	}
+	return shared
+}
+
+// redactedUID hashes uid, which can tell the event apart (e.g. a feed UID
+// or a title), while keeping it stable across fetches of the share.
+func redactedUID(uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return fmt.Sprintf("vanitycal-share-%x", sum[:16])
+}
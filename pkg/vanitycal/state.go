@@ -0,0 +1,131 @@
+package vanitycal
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// State remembers what was published for each UID across runs, so that
+// changed events get a higher SEQUENCE and unchanged ones keep their DTSTAMP.
+type State struct {
+	Events map[string]StateEntry `json:"events"`
+}
+
+// StateEntry is the state of one published event.
+type StateEntry struct {
+	Hash     string    `json:"hash"`
+	Sequence int       `json:"sequence"`
+	Stamp    time.Time `json:"stamp"`
+}
+
+// LoadState reads a state file, returning an empty state if it doesn't exist.
+func LoadState(path string) (*State, error) {
+	state := &State{Events: map[string]StateEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading state file: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Invalid state file %s: %w", path, err)
+	}
+	if state.Events == nil {
+		state.Events = map[string]StateEntry{}
+	}
+	return state, nil
+}
+
+// Save writes the state file.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("Error writing state file: %w", err)
+	}
+	return nil
+}
+
+// Apply sets the SEQUENCE and DTSTAMP of the events from the state, bumping
+// the sequence of events whose content changed since the last run, and
+// records them. Events no longer generated are forgotten.
+func (s *State) Apply(events []GeneratedEvent, now time.Time) {
+	now = now.UTC().Truncate(time.Second)
+	entries := make(map[string]StateEntry, len(events))
+	for i, event := range events {
+		hash := eventHash(event)
+		entry, found := s.Events[event.UID]
+		switch {
+		case !found:
+			entry = StateEntry{Hash: hash, Stamp: now}
+		case entry.Hash != hash:
+			entry = StateEntry{Hash: hash, Sequence: entry.Sequence + 1, Stamp: now}
+		}
+		entries[event.UID] = entry
+		events[i].Sequence = entry.Sequence
+		events[i].Stamp = entry.Stamp
+	}
+	s.Events = entries
+}
+
+// eventHash summarizes what subscribers see of an event.
+func eventHash(event GeneratedEvent) string {
+	parts := []string{
+		event.Date.Format("20060102"),
+		event.Summary,
+		event.Description,
+		event.Source,
+		event.Confidence,
+	}
+	// appended after the others, only when set, so that the hashes of
+	// all-day events without excluded dates don't change.
+	for _, day := range event.ExDates {
+		parts = append(parts, day.Format("20060102"))
+	}
+	if !event.Start.IsZero() {
+		parts = append(parts, event.Start.Format(time.RFC3339), event.End.Format(time.RFC3339))
+	}
+	if !event.Until.IsZero() {
+		parts = append(parts, event.Until.Format("20060102"))
+	}
+	if event.RRule != "" {
+		parts = append(parts, "RRULE:"+event.RRule)
+	}
+	if len(event.RDates) > 0 {
+		rdates := make([]string, 0, len(event.RDates))
+		for _, day := range event.RDates {
+			rdates = append(rdates, day.Format("20060102"))
+		}
+		parts = append(parts, "RDATE:"+strings.Join(rdates, ","))
+	}
+	if event.Location != "" || event.Geo != nil || event.URL != "" {
+		parts = append(parts, event.Location, fmt.Sprint(event.Geo), event.URL)
+	}
+	if event.Busy {
+		parts = append(parts, "busy")
+	}
+	if event.Status != "" || event.Class != "" {
+		parts = append(parts, event.Status, event.Class)
+	}
+	if event.Organizer != "" || len(event.Attendees) > 0 {
+		parts = append(parts, event.Organizer, strings.Join(event.Attendees, ","))
+	}
+	if len(event.Categories) > 0 {
+		parts = append(parts, strings.Join(event.Categories, ","))
+	}
+	for _, before := range event.Alarms {
+		parts = append(parts, before.String())
+	}
+	if event.Journal != "" {
+		parts = append(parts, event.Journal)
+	}
+	content := strings.Join(parts, "\x00")
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
@@ -0,0 +1,28 @@
+package vanitycal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventHashRecurrence(t *testing.T) {
+	day := time.Date(2000, time.March, 14, 0, 0, 0, 0, time.UTC)
+	base := GeneratedEvent{UID: "mom", Date: day, Summary: "Mom"}
+	yearly := base
+	yearly.RRule = "FREQ=YEARLY"
+	leap := base
+	leap.RRule = "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=1"
+	series := yearly
+	series.RDates = []time.Time{day.AddDate(5, 0, 0)}
+	excluded := yearly
+	excluded.ExDates = []time.Time{day.AddDate(5, 0, 0)}
+
+	seen := map[string]string{}
+	for name, event := range map[string]GeneratedEvent{"single": base, "yearly": yearly, "leap": leap, "series": series, "excluded": excluded} {
+		hash := eventHash(event)
+		if other, found := seen[hash]; found {
+			t.Errorf("%s and %s have the same hash", name, other)
+		}
+		seen[hash] = name
+	}
+}
@@ -0,0 +1,164 @@
+package vanitycal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"moul.io/vanitycal/pkg/datemath"
+)
+
+// TemplateData is what summary_template and description templates can
+// refer to, e.g. "{{.Title}} ({{.Duration}})" or "It has been exactly
+// {{.TotalDays}} days since we met on {{.OriginalDate}}."
+type TemplateData struct {
+	// Title is the title of the event.
+	Title string
+	// Duration is the milestone label, e.g. "10y", "D-7" or "100d", in the
+	// configured locale.
+	Duration string
+	// Emoji is the emoji of the event, e.g. "💚", possibly empty.
+	Emoji string
+	// MilestoneKind is the kind of milestone, e.g. "anniversary" or
+	// "countdown".
+	MilestoneKind string
+	// DDay is set on the day of the event itself, labeled "D-DAY".
+	DDay bool
+	// OriginalDate is the date of the event the milestone comes from, empty
+	// for aggregates and recurring events without a year.
+	OriginalDate string
+	// TotalDays and Years are the days and whole years from the original
+	// date to the milestone, negative for countdowns.
+	TotalDays Count
+	Years     int
+}
+
+// Count is a number of days, written with thousands separators (e.g.
+// "1,825") in templates.
+type Count int
+
+func (c Count) String() string {
+	digits := strconv.Itoa(int(c))
+	sign := ""
+	if c < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+// parseTemplate parses a summary or description template and checks that
+// it only refers to TemplateData fields.
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&strings.Builder{}, TemplateData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// summaryTemplate returns the summary template of event, from its own, its
+// type preset or the calendar's; nil for the default
+// "<title> - <duration> <emoji>".
+func summaryTemplate(config Config, event Event) (*template.Template, error) {
+	text := config.SummaryTemplate
+	if preset, found := lookupPreset(event); found && preset.SummaryTemplate != "" {
+		text = preset.SummaryTemplate
+	}
+	if event.SummaryTemplate != "" {
+		text = event.SummaryTemplate
+	}
+	if text == "" {
+		return nil, nil
+	}
+	return parseTemplate("summary_template", text)
+}
+
+// isDescriptionTemplate reports whether description uses template actions.
+func isDescriptionTemplate(description string) bool {
+	return strings.Contains(description, "{{")
+}
+
+// templateData returns the template data of milestone, whose event is on
+// original (zero when unknown).
+func templateData(milestone GeneratedEvent, original time.Time, config Config, event Event) TemplateData {
+	data := TemplateData{
+		Title:         milestone.Title,
+		Duration:      milestoneLabel(milestone.Duration, milestone.Date, config),
+		Emoji:         eventEmoji(config, event),
+		MilestoneKind: milestone.Kind,
+		DDay:          milestone.Duration == "D-DAY",
+	}
+	if original.IsZero() {
+		return data
+	}
+	data.OriginalDate = FormatDate(original, config.LanguageTag())
+	data.TotalDays = Count(int(milestone.Date.Sub(original).Hours() / 24))
+	data.Years = milestone.Date.Year() - original.Year()
+	// February 29 anniversaries count from February 28 in common years.
+	if data.Years > 0 && milestone.Date.Before(datemath.AddYears(original, data.Years, datemath.LeapDayFeb28)) {
+		data.Years--
+	} else if data.Years < 0 && milestone.Date.After(original.AddDate(data.Years, 0, 0)) {
+		data.Years++
+	}
+	return data
+}
+
+// expandDescriptions replaces the descriptions of the milestones of event,
+// whose date is original, with its description template expanded.
+func expandDescriptions(milestones []GeneratedEvent, event Event, original time.Time, config Config) error {
+	if !isDescriptionTemplate(event.Description) {
+		return nil
+	}
+	tmpl, err := parseTemplate("description", event.Description)
+	if err != nil {
+		return err
+	}
+	for i, milestone := range milestones {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, templateData(milestone, original, config, event)); err != nil {
+			return err
+		}
+		event.Description = b.String()
+		milestones[i].Description = normalizeText(annotateDescription(event), config.TextEncoding)
+	}
+	return nil
+}
+
+// Age styles of birthday summaries, see Event.Age.
+const (
+	AgeTurns   = "turns"
+	AgeOrdinal = "ordinal"
+	AgeHidden  = "hidden"
+)
+
+// birthdayTitle returns the title of a yearly milestone of a birthday
+// event, e.g. "Alice turns 32", reporting false for other milestones.
+func birthdayTitle(milestone GeneratedEvent, event Event, config Config) (string, bool) {
+	if event.Type != EventTypeBirthday || milestone.Kind != KindAnniversary || !strings.HasSuffix(milestone.Duration, "y") {
+		return "", false
+	}
+	age, err := strconv.Atoi(strings.TrimSuffix(milestone.Duration, "y"))
+	if err != nil || age <= 0 {
+		return "", false
+	}
+	labels := localeLabels(config.LanguageTag())
+	switch event.Age {
+	case AgeOrdinal:
+		ordinal := strconv.Itoa(age)
+		if labels.Ordinal != nil {
+			ordinal = labels.Ordinal(age)
+		}
+		return fmt.Sprintf(labels.OrdinalBirthday, milestone.Title, ordinal), true
+	case AgeHidden:
+		return fmt.Sprintf(labels.Birthday, milestone.Title), true
+	}
+	return fmt.Sprintf(labels.Turns, milestone.Title, age), true
+}
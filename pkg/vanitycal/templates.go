@@ -0,0 +1,104 @@
+package vanitycal
+
+import "sort"
+
+// Template pre-fills a new event of a common kind with a preset: its
+// milestones, emoji, summary template and alarms.
+type Template struct {
+	Name        string
+	Description string
+	Preset      Preset
+}
+
+// templates are shipped with the binary, see `vanitycal add`.
+var templates = []Template{
+	{
+		Name:        "wedding",
+		Description: "Wedding anniversary",
+		Preset:      presetWithAlarms(EventTypeWedding, "1w", "1d"),
+	},
+	{
+		Name:        "new-baby",
+		Description: "Birth",
+		Preset: Preset{
+			Pattern: Anniversary{
+				Years:  []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 20, 21, 25, 30},
+				Months: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 18},
+				Days:   []int{7, 100, 500, 1_000, 10_000},
+			},
+			Emoji:  "👶",
+			Alarms: []string{"1d"},
+		},
+	},
+	{
+		Name:        "new-job",
+		Description: "Work anniversary",
+		Preset:      presetWithAlarms(EventTypeWorkAnniversary, "1d"),
+	},
+	{
+		Name:        "house-purchase",
+		Description: "Moved in",
+		Preset: Preset{
+			Pattern: Anniversary{
+				Years: []int{1, 2, 3, 5, 10, 15, 20, 25, 30},
+				Days:  []int{100, 1_000},
+			},
+			Emoji:  "🏡",
+			Alarms: []string{"1d"},
+		},
+	},
+	{
+		// the date is the expiry date, only countdowns matter.
+		Name:        "server-cert",
+		Description: "Certificate expiry",
+		Preset: Preset{
+			Pattern: Anniversary{
+				Countdowns: []int{60, 30, 14, 7, 3, 1},
+			},
+			Emoji:           "🔒",
+			SummaryTemplate: "{{.Title}} expires {{.Duration}} {{.Emoji}}",
+			Alarms:          []string{"0"},
+		},
+	},
+}
+
+// presetWithAlarms returns the preset of an event type with alarms.
+func presetWithAlarms(eventType string, alarms ...string) Preset {
+	preset := presets[eventType]
+	preset.Alarms = alarms
+	return preset
+}
+
+// LookupTemplate returns the template with the given name.
+func LookupTemplate(name string) (Template, bool) {
+	for _, template := range templates {
+		if template.Name == name {
+			return template, true
+		}
+	}
+	return Template{}, false
+}
+
+// TemplateNames lists the available templates, sorted.
+func TemplateNames() []string {
+	names := make([]string, 0, len(templates))
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Event returns an event with the preset of the template.
+func (t Template) Event(title, date string) Event {
+	pattern, emoji := t.Preset.Pattern, t.Preset.Emoji
+	return Event{
+		Title:           title,
+		Date:            date,
+		Description:     t.Description,
+		Anniversaries:   &pattern,
+		Emoji:           &emoji,
+		SummaryTemplate: t.Preset.SummaryTemplate,
+		Alarms:          append([]string(nil), t.Preset.Alarms...),
+	}
+}
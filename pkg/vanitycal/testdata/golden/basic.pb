@@ -0,0 +1,19 @@
+
+
+BasicEurope/ParisZ
+#vanitycal-20100501-74abf7ac885541ff
+2010-05-01Us - D-DAY 💚*anniversary2Us:D-DAYT
+#vanitycal-20110501-5f3897f50a13a2de
+2011-05-01Us - 1y 💚*anniversary2Us:1yV
+#vanitycal-20200501-b54e20d165e607d3
+2020-05-01Us - 10y 💚*anniversary2Us:10yX
+#vanitycal-20100809-0e1ce5c9821e2d29
+2010-08-09Us - 100d 💚*anniversary2Us:100dZ
+#vanitycal-20130125-130bd25cb8becd3a
+2013-01-25Us - 1000d 💚*anniversary2Us:1000dT
+#vanitycal-20100424-bf017bec398784e1
+2010-04-24Us - D-7 💚*	countdown2Us:D-7X
+#vanitycal-20000304-e704fbc44cde94ef
+2000-03-04Mom 💚*	recurring2MomZFREQ=YEARLY\
+#vanitycal-20300501-19b77ccd02c7f07a
+2030-05-01Family - 20y 💚*	aggregate2Family:20y
\ No newline at end of file
@@ -0,0 +1,15 @@
+
+$
+VanityCal 💚Europe/Paris}
+#vanitycal-20150228-d7a714254868703a
+2015-02-28Cafe creme - D-DAY"Premiere fois a Zurich*anniversary2
+Cafe creme:D-DAYw
+#vanitycal-20200228-a51e10320516afe1
+2020-02-28Cafe creme - 5y"Premiere fois a Zurich*anniversary2
+Cafe creme:5yy
+#vanitycal-20250228-7bbda9e3471b84bf
+2025-02-28Cafe creme - 10y"Premiere fois a Zurich*anniversary2
+Cafe creme:10yw
+#vanitycal-20150227-49b146b2799438d6
+2015-02-27Cafe creme - D-1"Premiere fois a Zurich*	countdown2
+Cafe creme:D-1
\ No newline at end of file
@@ -0,0 +1,89 @@
+
+$
+VanityCal 💚Europe/Parise
+#vanitycal-20200515-7572e60c2daa478d
+2020-05-15Mariage - Jour J 💚*anniversary2Mariage:D-DAY`
+#vanitycal-20210515-b9960153686a48a8
+2021-05-15Mariage - 1 an 💚*anniversary2Mariage:1yi
+#vanitycal-20250515-d6ed1ab69c844f52
+2025-05-15Mariage - 5 ans 💚 (jeudi)*anniversary2Mariage:5yi
+#vanitycal-20230209-e8a1d379589a7d8c
+2023-02-09Mariage - 1000 jours 💚*anniversary2Mariage:1000db
+#vanitycal-20201115-b071c27e23bf4683
+2020-11-15Mariage - 6 mois 💚*anniversary2Mariage:6mj
+#vanitycal-20220415-37fde1626585c30a
+2022-04-15Mariage - 100 semaines 💚*anniversary2Mariage:100w
+#vanitycal-20350515-8a338704e1f6e9de
+2035-05-151Mariage - anniversaire d'or (15 ans) 💚 (mardi)*coincidence2Mariage:golden birthday (15y)
+#vanitycal-20211202-30ddd1fb8c0a4727
+2021-12-02*Mariage - jour palindrome (566 jours) 💚*coincidence2Mariage:palindrome day (566d)
+#vanitycal-20300302-7f2e0aea1b89cd37
+2030-03-024Mariage - jour palindrome (3578 jours) 💚 (samedi)*coincidence2Mariage:palindrome day (3578d)
+#vanitycal-20400402-29d8add8ae460f43
+2040-04-023Mariage - jour palindrome (7262 jours) 💚 (lundi)*coincidence2Mariage:palindrome day (7262d)
+#vanitycal-20500502-9828ba5a92bfefd3
+2050-05-024Mariage - jour palindrome (10944 jours) 💚 (lundi)*coincidence2Mariage:palindrome day (10944d)
+#vanitycal-20600602-d80a09767519da94
+2060-06-027Mariage - jour palindrome (14628 jours) 💚 (mercredi)*coincidence2Mariage:palindrome day (14628d)
+#vanitycal-20700702-dc0e3a70a859f0a8
+2070-07-027Mariage - jour palindrome (18310 jours) 💚 (mercredi)*coincidence2Mariage:palindrome day (18310d)
+#vanitycal-20800802-b8aea9a01191bb24
+2080-08-027Mariage - jour palindrome (21994 jours) 💚 (vendredi)*coincidence2Mariage:palindrome day (21994d)
+#vanitycal-20900902-a1d7eacdb5ed8255
+2090-09-025Mariage - jour palindrome (25677 jours) 💚 (samedi)*coincidence2Mariage:palindrome day (25677d)
+#vanitycal-21011012-52e445189b24ead8
+2101-10-127Mariage - jour palindrome (29734 jours) 💚 (mercredi)*coincidence2Mariage:palindrome day (29734d)
+#vanitycal-21100112-4a01f9e69af7a942
+2110-01-127Mariage - jour palindrome (32748 jours) 💚 (dimanche)*coincidence2Mariage:palindrome day (32748d)
+#vanitycal-21111112-d94e1e15a0877fa1
+2111-11-124Mariage - jour palindrome (33417 jours) 💚 (jeudi)*coincidence2Mariage:palindrome day (33417d)
+#vanitycal-21200212-756ea43b18130204
+2120-02-124Mariage - jour palindrome (36431 jours) 💚 (lundi)*coincidence2Mariage:palindrome day (36431d)
+#vanitycal-20240229-8b79f736ad0f42bb
+2024-02-297Mariage - premier 29 février (1385 jours) 💚 (jeudi)*	rare-date2Mariage:first leap day (1385d)
+#vanitycal-20260515-b05eca277d441d75
+2026-05-15NMariage - 1er anniversaire le même jour de la semaine (6 ans) 💚 (vendredi)*	rare-date2Mariage:!1st same-weekday anniversary (6y)
+#vanitycal-20540515-df6c8dfd5f6a2c26
+2054-05-15NMariage - 5e anniversaire le même jour de la semaine (34 ans) 💚 (vendredi)*	rare-date2Mariage:"5th same-weekday anniversary (34y)
+#vanitycal-20930515-8640320f42f2783f
+2093-05-15OMariage - 10e anniversaire le même jour de la semaine (73 ans) 💚 (vendredi)*	rare-date2Mariage:#10th same-weekday anniversary (73y)t
+#vanitycal-20300515-ad0796a3a803c5c4
+2030-05-15"Lancement - Jour J 💚 (mercredi)*anniversary2	Lancement:D-DAYl
+#vanitycal-20310515-0465cc71ab615448
+2031-05-15Lancement - 1 an 💚 (jeudi)*anniversary2	Lancement:1y
+#vanitycal-20450515-e8b2fc5d0efaea5a
+2045-05-153Lancement - anniversaire d'or (15 ans) 💚 (lundi)*coincidence2	Lancement:golden birthday (15y)
+#vanitycal-20400402-7165bbc2c90decda
+2040-04-025Lancement - jour palindrome (3610 jours) 💚 (lundi)*coincidence2	Lancement:palindrome day (3610d)
+#vanitycal-20500502-90987cc4631350ea
+2050-05-025Lancement - jour palindrome (7292 jours) 💚 (lundi)*coincidence2	Lancement:palindrome day (7292d)
+#vanitycal-20600602-a866b85cdeee3e35
+2060-06-029Lancement - jour palindrome (10976 jours) 💚 (mercredi)*coincidence2	Lancement:palindrome day (10976d)
+#vanitycal-20700702-15f104d453d91567
+2070-07-029Lancement - jour palindrome (14658 jours) 💚 (mercredi)*coincidence2	Lancement:palindrome day (14658d)
+#vanitycal-20800802-69fa82a9694c967e
+2080-08-029Lancement - jour palindrome (18342 jours) 💚 (vendredi)*coincidence2	Lancement:palindrome day (18342d)
+#vanitycal-20900902-d2daea19d497b92c
+2090-09-04ILancement - jour palindrome (22025 jours) (2 septembre 2090) 💚 (lundi)*coincidence2	Lancement:palindrome day (22025d)
+#vanitycal-21011012-6c9819b066ee82c6
+2101-10-129Lancement - jour palindrome (26082 jours) 💚 (mercredi)*coincidence2	Lancement:palindrome day (26082d)
+#vanitycal-21100112-fbab419cec299f0d
+2110-01-13HLancement - jour palindrome (29096 jours) (12 janvier 2110) 💚 (lundi)*coincidence2	Lancement:palindrome day (29096d)
+#vanitycal-21111112-1e25fb736d382510
+2111-11-126Lancement - jour palindrome (29765 jours) 💚 (jeudi)*coincidence2	Lancement:palindrome day (29765d)
+#vanitycal-21200212-3bedbd703e77ae35
+2120-02-126Lancement - jour palindrome (32779 jours) 💚 (lundi)*coincidence2	Lancement:palindrome day (32779d)
+#vanitycal-21211212-930aa902bf3abe4a
+2121-12-129Lancement - jour palindrome (33448 jours) 💚 (vendredi)*coincidence2	Lancement:palindrome day (33448d)
+#vanitycal-21300312-055f0eeb2ac0c820
+2130-03-13ELancement - jour palindrome (36460 jours) (12 mars 2130) 💚 (lundi)*coincidence2	Lancement:palindrome day (36460d)
+#vanitycal-20320229-1cce172da5d9b014
+2032-03-01KLancement - premier 29 février (655 jours) (29 février 2032) 💚 (lundi)*	rare-date2	Lancement:first leap day (655d)
+#vanitycal-20410515-ce16413e893d273b
+2041-05-15QLancement - 1er anniversaire le même jour de la semaine (11 ans) 💚 (mercredi)*	rare-date2	Lancement:"1st same-weekday anniversary (11y)
+#vanitycal-20690515-e1060fd2a9e218e0
+2069-05-15PLancement - 5e anniversaire le même jour de la semaine (39 ans) 💚 (mercredi)*	rare-date2	Lancement:"5th same-weekday anniversary (39y)
+#vanitycal-21090515-82ce28ed41a58443
+2109-05-15QLancement - 10e anniversaire le même jour de la semaine (79 ans) 💚 (mercredi)*	rare-date2	Lancement:#10th same-weekday anniversary (79y)m
+#vanitycal-20300508-796f87486885a64e
+2030-05-08Lancement - J-7 💚 (mercredi)*	countdown2	Lancement:D-7
\ No newline at end of file
@@ -0,0 +1,18 @@
+
+$
+VanityCal 💚Europe/Parisr
+#vanitycal-20270612-ab8b7a077a255431
+2027-06-12Wedding - D-DAY 💚*anniversary2Wedding:D-DAYl
+#vanitycal-20280612-072d3d519cfa2b0a
+2028-06-12Wedding - 1y 💚*anniversary2Wedding:1y؄l
+#vanitycal-20270605-bbc9366ba7a0af61
+2027-06-05Wedding - D-7 💚*	countdown2Wedding:D-7؅r
+#vanitycal-20000304-e704fbc44cde94ef
+2000-03-04Mom 💚*	recurring2MomZFREQ=YEARLYj
+2027-03-04p
+#vanitycal-20240202-c839ddf80cc3f204
+2024-02-02Launch - D-DAY 💚*anniversary2Launch:D-DAY
+#vanitycal-20240130-1ab5943128ad6596
+2024-01-30%Launch - D-3 · 24 working hours 💚*	countdown2Launch:D-3 · 24 working hours
+#vanitycal-20240126-e9dbe2f9ab3a1031
+2024-01-26%Launch - D-7 · 40 working hours 💚*	countdown2Launch:D-7 · 40 working hoursέέ
\ No newline at end of file
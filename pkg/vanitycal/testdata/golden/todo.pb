@@ -0,0 +1,18 @@
+
+$
+VanityCal 💚Europe/ParisU
+#vanitycal-20270515-9dd6a95dd33385be
+2027-05-15Taxes - due 💚*todo2Taxes:dueZ
+#vanitycal-20270508-b471526901c63134
+2027-05-08Taxes - D-7 💚*	countdown2Taxes:D-7Z
+#vanitycal-20270514-faed16ff50e75540
+2027-05-14Taxes - D-1 💚*	countdown2Taxes:D-1p
+#vanitycal-20200515-6766348faacd0c5e
+2020-05-15Wedding - D-DAY 💚"
+With Alice*anniversary2Wedding:D-DAY
+#vanitycal-20210515-25a6b131089d0e81
+2021-05-15Wedding - 1y 💚"
+With Alice*anniversary2Wedding:1y1 year since 2020-05-15
+#vanitycal-20250515-60193123b6cbf810
+2025-05-15Wedding - 5y 💚"
+With Alice*anniversary2Wedding:5y5 years since 2020-05-15
\ No newline at end of file
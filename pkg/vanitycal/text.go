@@ -0,0 +1,114 @@
+package vanitycal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	textEncodingUTF8  = "utf-8"
+	textEncodingASCII = "ascii"
+)
+
+func validateTextEncoding(encoding string) error {
+	switch encoding {
+	case "", textEncodingUTF8, textEncodingASCII:
+		return nil
+	}
+	return fmt.Errorf("unsupported text_encoding %q (expected %q or %q)", encoding, textEncodingUTF8, textEncodingASCII)
+}
+
+// normalizeText makes s safe to emit: invalid UTF-8 sequences are replaced,
+// the text is NFC-normalized, and in ascii mode everything that can't be
+// represented in plain ASCII is transliterated or dropped.
+func normalizeText(s string, encoding string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "\uFFFD")
+	}
+	if encoding != textEncodingASCII {
+		return norm.NFC.String(s)
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r): // combining accents
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			if repl, ok := asciiReplacements[r]; ok {
+				b.WriteString(repl)
+			}
+		}
+	}
+	// collapse the gaps left by dropped runes.
+	out := b.String()
+	for strings.Contains(out, "  ") {
+		out = strings.ReplaceAll(out, "  ", " ")
+	}
+	return strings.TrimRightFunc(out, unicode.IsSpace)
+}
+
+func ellipsisFor(encoding string) string {
+	if encoding == textEncodingASCII {
+		return "..."
+	}
+	return "…"
+}
+
+var asciiReplacements = map[rune]string{
+	'…': "...",
+	'–': "-",
+	'—': "-",
+	'‘': "'",
+	'’': "'",
+	'“': `"`,
+	'”': `"`,
+	'ß': "ss",
+	'æ': "ae",
+	'Æ': "AE",
+	'œ': "oe",
+	'Œ': "OE",
+	'ø': "o",
+	'Ø': "O",
+	'·': "-",
+}
+
+// truncateSummary joins title and suffix, trimming the title with an ellipsis
+// so that the result fits in max runes. The suffix is never trimmed.
+func truncateSummary(title, suffix string, max int, ellipsis string) string {
+	titleRunes := []rune(title)
+	suffixLen := len([]rune(suffix))
+	if max <= 0 || len(titleRunes)+suffixLen <= max {
+		return title + suffix
+	}
+
+	keep := max - suffixLen - len([]rune(ellipsis))
+	if keep <= 0 {
+		return strings.TrimLeftFunc(suffix, unicode.IsSpace)
+	}
+	trimmed := titleRunes[:keep]
+	// don't leave half of an emoji sequence (ZWJ, variation selectors, skin tones) behind.
+	for len(trimmed) > 0 && isEmojiJoiner(trimmed[len(trimmed)-1]) {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	return strings.TrimRightFunc(string(trimmed), unicode.IsSpace) + ellipsis + suffix
+}
+
+func isEmojiJoiner(r rune) bool {
+	switch {
+	case r == '\u200d': // zero width joiner
+		return true
+	case r >= '\ufe00' && r <= '\ufe0f': // variation selectors
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // skin tone modifiers
+		return true
+	}
+	return false
+}
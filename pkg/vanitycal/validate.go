@@ -0,0 +1,607 @@
+package vanitycal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"moul.io/vanitycal/pkg/datemath"
+)
+
+// position locates an entry of the config in its source file.
+type position struct {
+	file string
+	line int
+}
+
+func (p position) String() string {
+	switch {
+	case p.file == "":
+		return ""
+	case p.line == 0:
+		return p.file + ": "
+	default:
+		return fmt.Sprintf("%s:%d: ", p.file, p.line)
+	}
+}
+
+// ValidateConfig checks the config before generation, reporting every
+// problem found with the file and line of the offending entry.
+func ValidateConfig(config Config) error {
+	var errs []error
+	report := func(pos position, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s"+format, append([]interface{}{pos}, args...)...))
+	}
+
+	if err := validateTextEncoding(config.TextEncoding); err != nil {
+		report(position{}, "%v", err)
+	}
+	if config.Language != "" && config.Locale != "" && config.Language != config.Locale {
+		report(position{}, "language and locale (deprecated) can't both be set")
+	} else if err := validateLanguage(config.LanguageTag()); err != nil {
+		report(position{}, "%v", err)
+	}
+	if !validDurationStyle(config.DurationStyle) {
+		report(position{}, "duration_style: invalid style %q, expected one of %s", config.DurationStyle, strings.Join(durationStyles, ", "))
+	}
+	if config.CountdownFormat != "" {
+		if _, err := parseCountdownFormat(config.CountdownFormat); err != nil {
+			report(position{}, "countdown_format: %v", err)
+		}
+	}
+	if _, err := time.LoadLocation(config.timezone()); err != nil {
+		report(position{}, "invalid timezone: %v", err)
+	}
+
+	if config.Color != "" && !validColor(config.Color) {
+		report(position{}, "invalid color %q, expected a CSS color name or #rrggbb", config.Color)
+	}
+	if config.RefreshInterval != "" {
+		if interval, err := time.ParseDuration(config.RefreshInterval); err != nil || interval < time.Minute || interval%time.Minute != 0 {
+			report(position{}, "invalid refresh_interval %q, expected whole minutes like \"30m\" or \"12h\"", config.RefreshInterval)
+		}
+	}
+
+	if _, err := config.Workweek.parse(); err != nil {
+		report(position{}, "%v", err)
+	}
+
+	if strings.ContainsAny(config.UIDDomain, "@ \t") {
+		report(position{}, "invalid uid_domain %q, expected a domain name like cal.example.org", config.UIDDomain)
+	}
+	for _, generator := range config.RareDates {
+		if _, found := rareDateGenerators[generator]; !found {
+			report(position{}, "rare_dates: unknown generator %q, expected one of %s", generator, strings.Join(RareDateGenerators(), ", "))
+		}
+	}
+	if !validLeapDayPolicy(config.LeapDayPolicy) {
+		report(position{}, "leap_day_policy: invalid policy %q, expected %q or %q", config.LeapDayPolicy, datemath.LeapDayMar01, datemath.LeapDayFeb28)
+	}
+	if config.SummaryTemplate != "" {
+		if _, err := parseTemplate("summary_template", config.SummaryTemplate); err != nil {
+			report(position{}, "summary_template: %v", err)
+		}
+	}
+	if !validShift(config.Shift) || config.Shift == ShiftNone {
+		report(position{}, "shift: invalid rule %q, expected %q or %q", config.Shift, datemath.ShiftNextWeekday, datemath.ShiftNearestSaturday)
+	}
+	for _, feedURL := range config.ExtraFeeds {
+		if !IsURL(feedURL) {
+			report(position{}, "extra_feeds: %q is not an HTTP(S) URL", feedURL)
+		}
+	}
+
+	if config.Anniversaries != nil {
+		validatePattern(report, position{}, "anniversaries", *config.Anniversaries)
+	}
+	profiles := config.profiles()
+	for _, name := range sortedPatternNames(profiles) {
+		validatePattern(report, position{}, "profiles."+name, profiles[name])
+		_, inProfiles := config.Profiles[name]
+		if _, inPatterns := config.Patterns[name]; inProfiles && inPatterns {
+			report(position{}, "profiles.%s: also defined in patterns", name)
+		}
+	}
+
+	validateAlarms(report, config)
+
+	if config.RoundNumbersHorizon < 0 || config.RoundNumbersHorizon > milestoneHorizonYears {
+		report(position{}, "round_numbers_horizon: expected a number of years up to %d", milestoneHorizonYears)
+	}
+
+	tokens := map[string]bool{}
+	for i, share := range config.Shares {
+		name := fmt.Sprintf("shares %d (%q)", i+1, share.Name)
+		switch {
+		case len(share.Token) < minShareTokenLength:
+			report(position{}, "%s: token must be at least %d characters", name, minShareTokenLength)
+		case tokens[share.Token]:
+			report(position{}, "%s: token is already used by another share", name)
+		}
+		tokens[share.Token] = true
+	}
+
+	titles := map[string]bool{}
+	for i, event := range config.Events {
+		pos := event.position
+		name := fmt.Sprintf("event %d", i+1)
+		if event.Title == "" {
+			report(pos, "%s: title is required", name)
+		} else {
+			name = fmt.Sprintf("event %d (%q)", i+1, event.Title)
+			titles[event.Title] = true
+		}
+
+		_, _, yearless, err := eventMonthDay(event)
+		switch {
+		case err != nil:
+			report(pos, "%s: %v", name, err)
+		case yearless:
+		case event.Date == "":
+			report(pos, "%s: date or month_day is required", name)
+		default:
+			if _, err := time.Parse("2006-01-02", event.Date); err != nil {
+				report(pos, "%s: invalid date %q, expected YYYY-MM-DD", name, event.Date)
+			}
+		}
+
+		switch event.Type {
+		case "", EventTypeEvent:
+		case EventTypeTodo:
+			if yearless || event.Until != "" || event.EndDate != "" {
+				report(pos, "%s: todo events are due on a single date, they can't use month_day, until or end_date", name)
+			}
+			if event.AnniversaryOnly {
+				report(pos, "%s: todo events only have countdowns, anniversary_only doesn't apply", name)
+			}
+		default:
+			if _, found := lookupPreset(event); !found {
+				report(pos, "%s: invalid type %q, expected one of %s", name, event.Type, strings.Join(eventTypes(), ", "))
+			}
+		}
+		switch event.Age {
+		case "", AgeTurns, AgeOrdinal, AgeHidden:
+			if event.Age != "" && event.Type != EventTypeBirthday {
+				report(pos, "%s: age only applies to birthday events", name)
+			}
+		default:
+			report(pos, "%s: invalid age %q, expected %q, %q or %q", name, event.Age, AgeTurns, AgeOrdinal, AgeHidden)
+		}
+
+		for _, value := range event.Alarms {
+			if _, err := parseAlarmOffset(value); err != nil {
+				report(pos, "%s: alarms: %v", name, err)
+			}
+		}
+		if event.Anniversaries != nil {
+			validatePattern(report, pos, name+": anniversaries", *event.Anniversaries)
+		}
+		if event.Profile != "" && event.Patterns != "" && event.Profile != event.Patterns {
+			report(pos, "%s: profile and patterns (deprecated) can't both be set", name)
+		}
+		if profile := eventProfile(event); profile != "" {
+			if _, found := profiles[profile]; !found {
+				report(pos, "%s: unknown profile %q", name, profile)
+			}
+		}
+		for _, generator := range event.RareDates {
+			if _, found := rareDateGenerators[generator]; !found {
+				report(pos, "%s: unknown rare_dates generator %q, expected one of %s", name, generator, strings.Join(RareDateGenerators(), ", "))
+			}
+		}
+		if event.ShowProgress && event.Since == "" {
+			report(pos, "%s: show_progress requires since", name)
+		}
+		if event.Since != "" {
+			if _, err := time.Parse("2006-01-02", event.Since); err != nil {
+				report(pos, "%s: invalid since %q, expected YYYY-MM-DD", name, event.Since)
+			}
+		}
+		if event.Until != "" {
+			until, err := time.Parse("2006-01-02", event.Until)
+			date, dateErr := time.Parse("2006-01-02", event.Date)
+			switch {
+			case err != nil:
+				report(pos, "%s: invalid until %q, expected YYYY-MM-DD", name, event.Until)
+			case yearless:
+				report(pos, "%s: until requires a date, not month_day", name)
+			case dateErr == nil && until.Before(date):
+				report(pos, "%s: until is before date", name)
+			case event.Time != "":
+				report(pos, "%s: until can't be combined with time, multi-day events are all-day", name)
+			}
+		} else if event.AnniversaryFrom != "" {
+			report(pos, "%s: anniversary_from requires until", name)
+		}
+		switch event.AnniversaryFrom {
+		case "", AnniversaryFromStart, AnniversaryFromEnd:
+		default:
+			report(pos, "%s: invalid anniversary_from %q, expected %q or %q", name, event.AnniversaryFrom, AnniversaryFromStart, AnniversaryFromEnd)
+		}
+		if !validLeapDayPolicy(event.LeapDayPolicy) {
+			report(pos, "%s: invalid leap_day_policy %q, expected %q or %q", name, event.LeapDayPolicy, datemath.LeapDayMar01, datemath.LeapDayFeb28)
+		}
+		if event.Geo != nil && (len(event.Geo) != 2 || event.Geo[0] < -90 || event.Geo[0] > 90 || event.Geo[1] < -180 || event.Geo[1] > 180) {
+			report(pos, "%s: invalid geo %v, expected [latitude, longitude]", name, event.Geo)
+		}
+		if event.URL != "" && !IsURL(event.URL) {
+			report(pos, "%s: url %q is not an HTTP(S) URL", name, event.URL)
+		}
+		switch strings.ToUpper(event.Status) {
+		case "", "TENTATIVE", "CONFIRMED", "CANCELLED":
+		default:
+			report(pos, "%s: invalid status %q, expected TENTATIVE, CONFIRMED or CANCELLED", name, event.Status)
+		}
+		switch strings.ToUpper(event.Class) {
+		case "", "PUBLIC", "PRIVATE", "CONFIDENTIAL":
+		default:
+			report(pos, "%s: invalid class %q, expected PUBLIC, PRIVATE or CONFIDENTIAL", name, event.Class)
+		}
+		for _, address := range append([]string{event.Organizer}, event.Attendees...) {
+			if address != "" && !validEmail(address) {
+				report(pos, "%s: invalid e-mail address %q", name, address)
+			}
+		}
+		if event.SummaryTemplate != "" {
+			if _, err := parseTemplate("summary_template", event.SummaryTemplate); err != nil {
+				report(pos, "%s: invalid summary_template: %v", name, err)
+			}
+		}
+		if isDescriptionTemplate(event.Description) {
+			if _, err := parseTemplate("description", event.Description); err != nil {
+				report(pos, "%s: invalid description template: %v", name, err)
+			}
+		}
+		if !validShift(event.Shift) {
+			report(pos, "%s: invalid shift %q, expected %q, %q or %q", name, event.Shift, datemath.ShiftNextWeekday, datemath.ShiftNearestSaturday, ShiftNone)
+		}
+		if event.Time != "" {
+			if _, err := time.Parse("15:04", event.Time); err != nil {
+				report(pos, "%s: invalid time %q, expected HH:MM", name, event.Time)
+			}
+		} else if event.Duration != "" {
+			report(pos, "%s: duration requires time", name)
+		} else if event.BusinessHours {
+			report(pos, "%s: business_hours requires time", name)
+		}
+		if event.Duration != "" {
+			if duration, err := time.ParseDuration(event.Duration); err != nil || duration <= 0 {
+				report(pos, "%s: invalid duration %q, expected e.g. '2h' or '90m'", name, event.Duration)
+			}
+		}
+		switch {
+		case event.CountdownOnly && event.AnniversaryOnly:
+			report(pos, "%s: countdown_only and anniversary_only are mutually exclusive", name)
+		case event.CountdownOnly && event.EndDate != "":
+			report(pos, "%s: countdown_only events have no milestones after their date, end_date doesn't apply", name)
+		case event.AnniversaryOnly && event.ShowProgress:
+			report(pos, "%s: show_progress needs countdowns, which anniversary_only disables", name)
+		case event.AnniversaryOnly && event.BusinessHours:
+			report(pos, "%s: business_hours needs countdowns, which anniversary_only disables", name)
+		case event.AnniversaryOnly && event.DenseFinalWeek != nil && *event.DenseFinalWeek:
+			report(pos, "%s: dense_final_week needs countdowns, which anniversary_only disables", name)
+		}
+		if event.EndDate != "" {
+			end, err := time.Parse("2006-01-02", event.EndDate)
+			date, dateErr := time.Parse("2006-01-02", event.Date)
+			switch {
+			case err != nil:
+				report(pos, "%s: invalid end_date %q, expected YYYY-MM-DD", name, event.EndDate)
+			case yearless:
+				report(pos, "%s: end_date requires a date, not month_day", name)
+			case dateErr == nil && end.Before(date):
+				report(pos, "%s: end_date is before date", name)
+			}
+		} else if event.EndEvent {
+			report(pos, "%s: end_event requires end_date", name)
+		}
+		for _, day := range event.ExcludeDates {
+			if _, err := time.Parse("2006-01-02", day); err != nil {
+				report(pos, "%s: invalid exclude_dates entry %q, expected YYYY-MM-DD", name, day)
+			}
+		}
+	}
+
+	for i, nextUp := range config.NextUp {
+		pos := nextUp.position
+		name := fmt.Sprintf("next_up %d", i+1)
+		if nextUp.Title == "" {
+			report(pos, "%s: title is required", name)
+		} else {
+			name = fmt.Sprintf("next_up %d (%q)", i+1, nextUp.Title)
+		}
+		if len(nextUp.Tags) == 0 {
+			report(pos, "%s: tags is required", name)
+		}
+	}
+
+	for i, aggregate := range config.Aggregates {
+		pos := aggregate.position
+		name := fmt.Sprintf("aggregate %d", i+1)
+		if aggregate.Title == "" {
+			report(pos, "%s: title is required", name)
+		} else {
+			name = fmt.Sprintf("aggregate %d (%q)", i+1, aggregate.Title)
+		}
+		if len(aggregate.Events) == 0 {
+			report(pos, "%s: events is required", name)
+		}
+		for _, title := range aggregate.Events {
+			if !titles[title] {
+				report(pos, "%s: unknown event %q", name, title)
+			}
+		}
+		validatePattern(report, pos, name, Anniversary{Years: aggregate.Years, Days: aggregate.Days})
+	}
+
+	return errors.Join(errs...)
+}
+
+// LintConfig reports suspicious entries of a valid config that don't
+// prevent generation: duplicate titles, missing descriptions, patterns that
+// generate nothing, and events whose every milestone is before now.
+func LintConfig(config Config, now time.Time) []string {
+	warnings := []string{}
+	warn := func(pos position, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("%s"+format, append([]interface{}{pos}, args...)...))
+	}
+
+	if config.Anniversaries != nil && isEmptyPattern(*config.Anniversaries) {
+		warn(position{}, "anniversaries: pattern generates nothing")
+	}
+	profiles := config.profiles()
+	for _, name := range sortedPatternNames(profiles) {
+		if isEmptyPattern(profiles[name]) {
+			warn(position{}, "profiles.%s: pattern generates nothing", name)
+		}
+	}
+
+	titles := map[string]int{}
+	for i, event := range config.Events {
+		pos := event.position
+		name := fmt.Sprintf("event %d (%q)", i+1, event.Title)
+		if first, found := titles[event.Title]; found {
+			warn(pos, "%s: duplicate title, also used by event %d", name, first)
+		} else {
+			titles[event.Title] = i + 1
+		}
+		if event.Description == "" {
+			warn(pos, "%s: no description", name)
+		}
+		if event.Anniversaries != nil && isEmptyPattern(*event.Anniversaries) {
+			warn(pos, "%s: anniversaries pattern generates nothing", name)
+		}
+
+		// yearless events recur, they always have upcoming milestones.
+		if _, _, yearless, _ := eventMonthDay(event); yearless {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", event.Date)
+		if err != nil {
+			continue
+		}
+		pattern, err := eventPattern(config, event)
+		if err != nil {
+			continue
+		}
+		// countdowns come before the date, the last milestone is an anniversary.
+		last := date
+		anniversaries := datemath.Anniversaries(date, pattern.Years, pattern.Months, pattern.Days)
+		for _, anniv := range append(anniversaries, datemath.WeekAnniversaries(date, pattern.Weeks)...) {
+			if anniv.After(last) {
+				last = anniv
+			}
+		}
+		if last.Before(now) {
+			warn(pos, "%s: every milestone is in the past (last on %s)", name, last.Format("2006-01-02"))
+		}
+	}
+
+	warnings = append(warnings, LintHorizon(config)...)
+	return append(warnings, LintDST(config)...)
+}
+
+// LintDST reports the timed milestones falling in a DST gap or overlap of
+// the calendar timezone, see Event.Time for how they are resolved.
+func LintDST(config Config) []string {
+	warnings := []string{}
+	location, err := time.LoadLocation(config.timezone())
+	if err != nil {
+		return warnings
+	}
+	for i, event := range config.Events {
+		clock, err := time.Parse("15:04", event.Time)
+		if err != nil {
+			continue
+		}
+		// generate the event alone, without aggregates nor feeds.
+		single := config
+		single.Events = []Event{event}
+		single.Aggregates = nil
+		single.NextUp = nil
+		single.ExtraFeeds = nil
+		single.ExtraEvents = ""
+		generated, err := GenerateEvents(single)
+		if err != nil {
+			continue
+		}
+		name := fmt.Sprintf("event %d (%q)", i+1, event.Title)
+		for _, milestone := range generated {
+			if milestone.Start.IsZero() {
+				continue
+			}
+			switch t, transition := localTime(milestone.Date, clock, location); transition {
+			case dstGap:
+				warnings = append(warnings, fmt.Sprintf("%s%s: %s %s does not exist in %s (DST gap), moved to %s", event.position, name, milestone.Date.Format("2006-01-02"), event.Time, location, t.Format("15:04 MST")))
+			case dstOverlap:
+				warnings = append(warnings, fmt.Sprintf("%s%s: %s %s is ambiguous in %s (DST overlap), using %s", event.position, name, milestone.Date.Format("2006-01-02"), event.Time, location, t.Format("15:04 MST")))
+			}
+		}
+	}
+	return warnings
+}
+
+// LintHorizon returns warnings for the milestones skipped because they are
+// more than 200 years away from their event.
+func LintHorizon(config Config) []string {
+	warnings := []string{}
+	warn := func(pos position, name string, dropped []string) {
+		if len(dropped) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s%s: %s beyond the %d-year horizon, skipped", pos, name, strings.Join(dropped, ", "), milestoneHorizonYears))
+		}
+	}
+	for i, event := range config.Events {
+		if pattern, err := resolvePattern(config, event); err == nil {
+			_, dropped := clampPattern(pattern)
+			warn(event.position, fmt.Sprintf("event %d (%q)", i+1, event.Title), dropped)
+		}
+	}
+	for i, aggregate := range config.Aggregates {
+		_, dropped := clampPattern(Anniversary{Years: aggregate.Years, Days: aggregate.Days})
+		warn(aggregate.position, fmt.Sprintf("aggregate %d (%q)", i+1, aggregate.Title), dropped)
+	}
+	return warnings
+}
+
+// validatePattern reports the negative milestones and the invalid every
+// entries of pattern.
+func validatePattern(report func(position, string, ...interface{}), pos position, name string, pattern Anniversary) {
+	for _, field := range []struct {
+		name   string
+		values []int
+	}{
+		{"years", pattern.Years},
+		{"months", pattern.Months},
+		{"weeks", pattern.Weeks},
+		{"days", pattern.Days},
+		{"countdowns", pattern.Countdowns},
+	} {
+		for _, value := range field.values {
+			if value < 0 {
+				report(pos, "%s: %s %d: milestones can't be negative", name, field.name, value)
+			}
+		}
+	}
+	for i, interval := range pattern.Every {
+		units := 0
+		for _, step := range []int{interval.Years, interval.Months, interval.Weeks, interval.Days} {
+			if step != 0 {
+				units++
+			}
+		}
+		_, step := interval.unit()
+		switch {
+		case units != 1:
+			report(pos, "%s: every %d: expected exactly one of years, months, weeks or days", name, i+1)
+		case step < 0:
+			report(pos, "%s: every %d: interval must be positive", name, i+1)
+		case interval.Until < step:
+			report(pos, "%s: every %d: until must be at least the interval", name, i+1)
+		}
+	}
+}
+
+func sortedPatternNames(patterns map[string]Anniversary) []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isEmptyPattern(pattern Anniversary) bool {
+	return len(pattern.Years) == 0 && len(pattern.Months) == 0 && len(pattern.Days) == 0 && len(pattern.Weeks) == 0 && len(pattern.Countdowns) == 0 && len(pattern.Every) == 0
+}
+
+var tomlTableHeader = regexp.MustCompile(`^\s*\[\[\s*([A-Za-z0-9_.-]+)\s*\]\]`)
+
+// tableLines returns, for each entry of the top-level array named table,
+// the line where it starts in data (0 when unknown).
+func tableLines(data []byte, format, table string) []int {
+	lines := []int{}
+	switch format {
+	case FormatTOML:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for n := 1; scanner.Scan(); n++ {
+			if match := tomlTableHeader.FindStringSubmatch(scanner.Text()); match != nil && match[1] == table {
+				lines = append(lines, n)
+			}
+		}
+	case FormatYAML:
+		var root yaml.Node
+		if yaml.Unmarshal(data, &root) != nil || len(root.Content) == 0 {
+			return nil
+		}
+		mapping := root.Content[0]
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == table {
+				for _, item := range mapping.Content[i+1].Content {
+					lines = append(lines, item.Line)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// validLeapDayPolicy reports whether policy is unset or a datemath leap-day
+// policy.
+func validLeapDayPolicy(policy string) bool {
+	return policy == "" || policy == datemath.LeapDayMar01 || policy == datemath.LeapDayFeb28
+}
+
+// validDurationStyle reports whether style is unset or a duration style.
+func validDurationStyle(style string) bool {
+	if style == "" {
+		return true
+	}
+	for _, known := range durationStyles {
+		if style == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validShift reports whether rule is unset, a datemath shift rule or
+// ShiftNone.
+func validShift(rule string) bool {
+	switch rule {
+	case "", datemath.ShiftNextWeekday, datemath.ShiftNearestSaturday, ShiftNone:
+		return true
+	}
+	return false
+}
+
+// validColor reports whether color is "#rrggbb" or looks like a CSS color
+// name (letters only).
+func validColor(color string) bool {
+	if strings.HasPrefix(color, "#") {
+		_, err := hex.DecodeString(color[1:])
+		return len(color) == 7 && err == nil
+	}
+	for _, r := range color {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validEmail reports whether address is a bare e-mail address, optionally
+// prefixed with "mailto:".
+func validEmail(address string) bool {
+	address = strings.TrimPrefix(address, "mailto:")
+	parsed, err := mail.ParseAddress(address)
+	return err == nil && parsed.Address == address
+}
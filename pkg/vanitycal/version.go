@@ -0,0 +1,29 @@
+package vanitycal
+
+import "runtime/debug"
+
+// modulePath is the path of this module in build info.
+const modulePath = "moul.io/vanitycal"
+
+// Version returns the version of the vanitycal module the binary was built
+// with, e.g. "v1.4.0", or "devel" for local builds.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		// embedded as a library.
+		version = ""
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+			}
+		}
+	}
+	if version == "" || version == "(devel)" {
+		return "devel"
+	}
+	return version
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"moul.io/vanitycal/pkg/datemath"
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// runPreview implements `vanitycal preview`: it prints the generated events
+// falling on a given day, or the agenda of the next days, to check patterns
+// before publishing. The WHEN column says how far each day is from today.
+func runPreview(args []string) int {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	var configs configFlags
+	configs.register(fs)
+	on := fs.String("on", "", "Day to preview (YYYY-MM-DD, default: today)")
+	next := fs.String("next", "", "Preview the agenda of this period from today instead of a single day, e.g. '90d' or '12w'")
+	_ = fs.Parse(args)
+
+	if *on != "" && *next != "" {
+		fmt.Fprintln(os.Stderr, "on and next are mutually exclusive")
+		return exitUsage
+	}
+	var day time.Time
+	if *on != "" {
+		var err error
+		day, err = time.Parse("2006-01-02", *on)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("Invalid date: %w", err))
+			return exitUsage
+		}
+	}
+	days := 0
+	if *next != "" {
+		var err error
+		days, err = parseDays(*next)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+
+	config, _, err := configs.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	today := config.Today()
+	if *on == "" {
+		day = today
+	}
+	until := day.AddDate(0, 0, days)
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitValidationError
+	}
+
+	matching := []vanitycal.GeneratedEvent{}
+	for _, event := range vanitycal.ExpandRecurring(events, day, until) {
+		if !event.Date.Before(day) && !event.Date.After(until) {
+			matching = append(matching, event)
+		}
+	}
+	if len(matching) == 0 {
+		if *next != "" {
+			fmt.Printf("No events in the next %s\n", *next)
+		} else {
+			fmt.Printf("No events on %s (%s)\n", vanitycal.FormatDate(day, config.LanguageTag()), datemath.RelativeDay(day, today))
+		}
+		return exitOK
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].Date.Before(matching[j].Date) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tWHEN\tKIND\tEVENT\tSUMMARY")
+	for _, event := range matching {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", vanitycal.FormatDate(event.Date, config.LanguageTag()), datemath.RelativeDay(event.Date, today), event.Kind, event.Title, event.Summary)
+	}
+	_ = w.Flush()
+	return exitOK
+}
+
+// parseDays parses a period given in days or weeks, e.g. "90d" or "12w".
+func parseDays(value string) (int, error) {
+	number, unit := value, 1
+	switch {
+	case strings.HasSuffix(value, "d"):
+		number = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		number, unit = strings.TrimSuffix(value, "w"), 7
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid period %q, expected a number of days like '90d' or weeks like '12w'", value)
+	}
+	return n * unit, nil
+}
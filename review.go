@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// reviewGroup lists the milestones of one event or tag.
+type reviewGroup struct {
+	Name       string
+	Milestones []vanitycal.GeneratedEvent
+}
+
+// yearReview is the model rendered by `vanitycal review`.
+type yearReview struct {
+	Year       int
+	Language   string
+	Theme      string
+	Highlights []vanitycal.GeneratedEvent
+	Groups     []reviewGroup
+}
+
+// reviewHighlights is how many of the biggest milestones are highlighted.
+const reviewHighlights = 3
+
+// runReview implements `vanitycal review`: it writes a Markdown or HTML recap
+// of the milestones that occurred in a year.
+func runReview(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	var configs configFlags
+	configs.register(fs)
+	year := fs.Int("year", 0, "Year to review (default: the previous year)")
+	groupBy := fs.String("group-by", "event", "Group milestones by 'event' or 'tag'")
+	outputFormat := fs.String("output-format", "markdown", "Output format: 'markdown' or 'html'")
+	output := fs.String("output", "-", "Path to the output file (use '-' for stdout)")
+	theme := fs.String("theme", "default", "HTML theme: 'default' or 'high-contrast'")
+	_ = fs.Parse(args)
+
+	if *groupBy != "event" && *groupBy != "tag" {
+		fmt.Fprintln(os.Stderr, "Invalid group-by, expected 'event' or 'tag'")
+		return exitUsage
+	}
+	if *outputFormat != "markdown" && *outputFormat != "html" {
+		fmt.Fprintln(os.Stderr, "Invalid output-format, expected 'markdown' or 'html'")
+		return exitUsage
+	}
+	if *theme != "default" && *theme != "high-contrast" {
+		fmt.Fprintln(os.Stderr, "Invalid theme, expected 'default' or 'high-contrast'")
+		return exitUsage
+	}
+
+	config, _, err := configs.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	if *year == 0 {
+		*year = config.Today().Year() - 1
+	}
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitValidationError
+	}
+
+	review := buildReview(events, *year, *groupBy)
+	review.Language = config.LanguageTag()
+	review.Theme = *theme
+	var buf strings.Builder
+	if *outputFormat == "html" {
+		err = writeReviewHTML(&buf, review)
+	} else {
+		writeReviewMarkdown(&buf, review)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	if err := writeOutput(*output, []byte(buf.String())); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	return exitOK
+}
+
+func buildReview(events []vanitycal.GeneratedEvent, year int, groupBy string) yearReview {
+	review := yearReview{Year: year}
+	milestones := []vanitycal.GeneratedEvent{}
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, event := range vanitycal.ExpandRecurring(events, first, first.AddDate(1, 0, -1)) {
+		switch event.Kind {
+		case vanitycal.KindAnniversary, vanitycal.KindAggregate, vanitycal.KindCoincidence, vanitycal.KindRareDate, vanitycal.KindRecurring:
+			if event.Date.Year() == year {
+				milestones = append(milestones, event)
+			}
+		}
+	}
+	sort.SliceStable(milestones, func(i, j int) bool { return milestones[i].Date.Before(milestones[j].Date) })
+
+	groups := map[string][]vanitycal.GeneratedEvent{}
+	for _, milestone := range milestones {
+		keys := []string{milestone.Title}
+		if groupBy == "tag" {
+			keys = milestone.Tags
+			if len(keys) == 0 {
+				keys = []string{"untagged"}
+			}
+		}
+		for _, key := range keys {
+			groups[key] = append(groups[key], milestone)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		review.Groups = append(review.Groups, reviewGroup{Name: name, Milestones: groups[name]})
+	}
+
+	biggest := append([]vanitycal.GeneratedEvent{}, milestones...)
+	sort.SliceStable(biggest, func(i, j int) bool { return milestoneSize(biggest[i]) > milestoneSize(biggest[j]) })
+	for _, milestone := range biggest {
+		if len(review.Highlights) == reviewHighlights || milestoneSize(milestone) == 0 {
+			break
+		}
+		review.Highlights = append(review.Highlights, milestone)
+	}
+	return review
+}
+
+// milestoneSize approximates the duration of a milestone in days, from its
+// "10y", "6m" or "100d" label; 0 when it has none.
+func milestoneSize(event vanitycal.GeneratedEvent) int {
+	duration := event.Duration
+	if len(duration) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(duration[:len(duration)-1])
+	if err != nil {
+		return 0
+	}
+	switch duration[len(duration)-1] {
+	case 'y':
+		return n * 365
+	case 'm':
+		return n * 30
+	case 'w':
+		return n * 7
+	case 'd':
+		return n
+	}
+	return 0
+}
+
+func writeReviewMarkdown(w io.Writer, review yearReview) {
+	fmt.Fprintf(w, "# %d in review\n", review.Year)
+	if len(review.Groups) == 0 {
+		fmt.Fprintln(w, "\nNo milestones this year.")
+		return
+	}
+	if len(review.Highlights) > 0 {
+		fmt.Fprintln(w, "\n## Highlights")
+		fmt.Fprintln(w)
+		for _, milestone := range review.Highlights {
+			fmt.Fprintf(w, "- **%s**: %s\n", vanitycal.FormatDate(milestone.Date, review.Language), milestone.Summary)
+		}
+	}
+	for _, group := range review.Groups {
+		fmt.Fprintf(w, "\n## %s\n\n", group.Name)
+		for _, milestone := range group.Milestones {
+			fmt.Fprintf(w, "- %s: %s\n", vanitycal.FormatDate(milestone.Date, review.Language), milestone.Summary)
+		}
+	}
+}
+
+// kindLabels name the milestone kinds in the HTML report, so that they are
+// read out rather than conveyed by styling only.
+var kindLabels = map[string]string{
+	vanitycal.KindAnniversary: "Anniversary",
+	vanitycal.KindAggregate:   "Combined milestone",
+	vanitycal.KindCoincidence: "Coincidence",
+	vanitycal.KindRareDate:    "Rare date",
+	vanitycal.KindRecurring:   "Yearly",
+}
+
+// reviewHTML is semantic, screen-reader friendly markup: tables labelled by
+// their heading with header cells, <time> dates, and colors meeting WCAG AA
+// contrast (AAA with the high-contrast theme or prefers-contrast).
+var reviewHTML = template.Must(template.New("review").Funcs(template.FuncMap{
+	"date": vanitycal.FormatDate,
+	"kind": func(kind string) string { return kindLabels[kind] },
+}).Parse(`<!DOCTYPE html>
+<html lang="{{with .Language}}{{.}}{{else}}en{{end}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Year}} in review</title>
+<style>
+body { font-family: system-ui, sans-serif; line-height: 1.5; max-width: 48rem; margin: 0 auto; padding: 1rem; color: #1a1a1a; background: #ffffff; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { text-align: left; padding: 0.25rem 0.5rem; border-bottom: 1px solid #595959; }
+.high-contrast { color: #000000; background: #ffffff; }
+.high-contrast th, .high-contrast td { border-bottom: 2px solid #000000; }
+@media (prefers-contrast: more) {
+  body { color: #000000; }
+  th, td { border-bottom: 2px solid #000000; }
+}
+@media (prefers-reduced-motion: reduce) {
+  * { animation: none !important; transition: none !important; scroll-behavior: auto !important; }
+}
+</style>
+</head>
+<body{{if eq .Theme "high-contrast"}} class="high-contrast"{{end}}>
+<main>
+<h1>{{.Year}} in review</h1>
+{{- if not .Groups}}
+<p>No milestones this year.</p>
+{{- end}}
+{{- if .Highlights}}
+<section aria-labelledby="highlights">
+<h2 id="highlights">Highlights</h2>
+<table aria-labelledby="highlights">
+<thead>
+<tr><th scope="col">Date</th><th scope="col">Milestone</th><th scope="col">Type</th></tr>
+</thead>
+<tbody>
+{{- range .Highlights}}
+<tr><td><time datetime="{{.Date.Format "2006-01-02"}}"><strong>{{date .Date $.Language}}</strong></time></td><td>{{.Summary}}</td><td>{{kind .Kind}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{- end}}
+{{- range $i, $group := .Groups}}
+<section aria-labelledby="group-{{$i}}">
+<h2 id="group-{{$i}}">{{.Name}}</h2>
+<table aria-labelledby="group-{{$i}}">
+<thead>
+<tr><th scope="col">Date</th><th scope="col">Milestone</th><th scope="col">Type</th></tr>
+</thead>
+<tbody>
+{{- range .Milestones}}
+<tr><td><time datetime="{{.Date.Format "2006-01-02"}}">{{date .Date $.Language}}</time></td><td>{{.Summary}}</td><td>{{kind .Kind}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+func writeReviewHTML(w io.Writer, review yearReview) error {
+	return reviewHTML.Execute(w, review)
+}
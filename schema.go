@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// runSchema implements `vanitycal schema`: it prints a JSON Schema of the
+// config, derived from the Go types so that it never drifts from them.
+func runSchema(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "schema takes no arguments")
+		return exitUsage
+	}
+
+	data, err := json.MarshalIndent(vanitycal.ConfigSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// runServe implements `vanitycal serve`: the calendar is regenerated from the
+// config on every request, so edits are picked up without a restart. The
+// events API is enabled by VANITYCAL_API_TOKEN or OIDC authentication.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var configs configFlags
+	configs.register(fs)
+	listen := fs.String("listen", ":8080", "Address to listen on (ignored when started by systemd socket activation)")
+	gracePeriod := fs.Duration("grace-period", 10*time.Second, "How long to wait for in-flight requests on SIGINT/SIGTERM")
+	oidcIssuer := fs.String("oidc-issuer", "", "Require bearer tokens issued by this OpenID Connect provider")
+	oidcAudience := fs.String("oidc-audience", "", "Audience (client id) expected in OIDC bearer tokens")
+	_ = fs.Parse(args)
+
+	if len(configs.paths) == 0 {
+		fmt.Fprintln(os.Stderr, "serve requires a config file")
+		fs.Usage()
+		return exitUsage
+	}
+	for _, path := range configs.paths {
+		if path == "-" {
+			fmt.Fprintln(os.Stderr, "serve can't read its config from stdin")
+			return exitUsage
+		}
+	}
+	if _, _, err := configs.load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+
+	var calendar http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveCalendar(w, r, &configs, nil)
+	})
+	// the calendar is public unless OIDC is configured, the API requires
+	// a token: the same tokens are accepted on both.
+	auth := bearerAuth{token: os.Getenv(apiTokenEnv)}
+	if *oidcIssuer != "" {
+		if *oidcAudience == "" {
+			fmt.Fprintln(os.Stderr, "oidc-issuer requires oidc-audience")
+			return exitUsage
+		}
+		verifier, err := newOIDCVerifier(*oidcIssuer, *oidcAudience)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfigError
+		}
+		auth.verifier = verifier
+		calendar = auth.middleware(calendar)
+	}
+	var api http.Handler
+	if auth.token != "" || auth.verifier != nil {
+		api = auth.middleware(&eventsAPI{configs: &configs})
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/calendar.ics", calendar)
+	if api != nil {
+		mux.Handle("/api/calendars/", api)
+	}
+	// share links carry their own secret, they are meant for people without
+	// an account.
+	mux.HandleFunc("/share/", func(w http.ResponseWriter, r *http.Request) {
+		serveShare(w, r, &configs)
+	})
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := systemdListener()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("Error using systemd socket: %w", err))
+		return exitUsage
+	}
+	if listener == nil {
+		listener, err = net.Listen("tcp", *listen)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("Error listening: %w", err))
+			return exitOutputError
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("serving %s on %s", configs.paths.String(), listener.Addr())
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		fmt.Fprintln(os.Stderr, fmt.Errorf("Error serving: %w", err))
+		return exitOutputError
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", *gracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("Error shutting down: %w", err))
+		return exitOutputError
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("Error serving: %w", err))
+		return exitOutputError
+	}
+	return exitOK
+}
+
+// listenFdsStart is the first file descriptor passed by systemd, see sd_listen_fds(3).
+const listenFdsStart = 3
+
+// systemdListener returns the socket passed by systemd socket activation, or
+// nil when the process wasn't socket-activated.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	if fds > 1 {
+		return nil, fmt.Errorf("expected a single socket, got %d", fds)
+	}
+	// don't pass the sockets down to child processes.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(listenFdsStart, "systemd-socket")
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+// serveCalendar writes the calendar, or the view of it through share when
+// set.
+func serveCalendar(w http.ResponseWriter, r *http.Request, configs *configFlags, share *vanitycal.Share) {
+	configFilesMu.RLock()
+	config, _, err := configs.load()
+	configFilesMu.RUnlock()
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "invalid config", http.StatusInternalServerError)
+		return
+	}
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "invalid config", http.StatusInternalServerError)
+		return
+	}
+	if share != nil {
+		events = share.Apply(events)
+		// hand-written events can't be redacted.
+		config.ExtraEvents = ""
+	}
+	var buf bytes.Buffer
+	if err := vanitycal.RenderICal(config, events, &buf); err != nil {
+		log.Print(err)
+		http.Error(w, "generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	// clients poll the feed: let them revalidate it cheaply.
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+	w.Header().Set("Cache-Control", feedCacheControl(config))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// feedCacheControl returns the Cache-Control of the served calendar: it is
+// personal, and fresh for the refresh_interval when set.
+func feedCacheControl(config vanitycal.Config) string {
+	if interval, err := time.ParseDuration(config.RefreshInterval); err == nil && interval > 0 {
+		return fmt.Sprintf("private, max-age=%d", int(interval.Seconds()))
+	}
+	return "private, no-cache"
+}
+
+// serveShare serves /share/<token>.ics.
+func serveShare(w http.ResponseWriter, r *http.Request, configs *configFlags) {
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/share/"), ".ics")
+	configFilesMu.RLock()
+	config, _, err := configs.load()
+	configFilesMu.RUnlock()
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "invalid config", http.StatusInternalServerError)
+		return
+	}
+	for _, share := range config.Shares {
+		if len(share.Token) > 0 && subtle.ConstantTimeCompare([]byte(share.Token), []byte(token)) == 1 {
+			serveCalendar(w, r, configs, &share)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// snapshotsDir is where the versions of the configs edited through the
+// events API are kept, next to the configs: .vanitycal-snapshots/<file>/<N>.
+const snapshotsDir = ".vanitycal-snapshots"
+
+// snapshotDir returns the directory keeping the versions of the config at
+// path.
+func snapshotDir(path string) string {
+	return filepath.Join(filepath.Dir(path), snapshotsDir, filepath.Base(path))
+}
+
+// snapshotVersions returns the versions of the config at path, oldest first.
+func snapshotVersions(path string) ([]int, error) {
+	entries, err := os.ReadDir(snapshotDir(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := []int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if version, err := strconv.Atoi(strings.TrimSuffix(name, filepath.Ext(name))); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func snapshotPath(path string, version int) string {
+	return filepath.Join(snapshotDir(path), strconv.Itoa(version)+filepath.Ext(path))
+}
+
+// saveSnapshot keeps data as the next version of the config at path, which
+// is about to be replaced by it. The first snapshot also keeps the current
+// content, so that the first change can be rolled back too.
+func saveSnapshot(path string, data []byte) (int, error) {
+	versions, err := snapshotVersions(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(snapshotDir(path), 0o700); err != nil {
+		return 0, err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	} else if current, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(snapshotPath(path, next), current, 0o600); err != nil {
+			return 0, err
+		}
+		next++
+	}
+	return next, os.WriteFile(snapshotPath(path, next), data, 0o600)
+}
+
+// runRollback implements `vanitycal rollback`: it lists the versions of a
+// config edited through the events API, or restores one of them.
+func runRollback(args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	path := fs.String("config", "", "Path of the served config file to roll back")
+	to := fs.Int("to", 0, "Version to restore (default: list the versions)")
+	_ = fs.Parse(args)
+
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "rollback requires a config file")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := checkEditableConfig(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *path, err)
+		return exitUsage
+	}
+	versions, err := snapshotVersions(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+
+	if *to == 0 {
+		for _, version := range versions {
+			info, err := os.Stat(snapshotPath(*path, version))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitConfigError
+			}
+			fmt.Printf("%d\t%s\n", version, info.ModTime().Format("2006-01-02 15:04:05"))
+		}
+		return exitOK
+	}
+
+	data, err := os.ReadFile(snapshotPath(*path, *to))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: no version %d\n", *path, *to)
+		return exitUsage
+	}
+	// check the version still loads next to the config, includes may have
+	// changed since.
+	tmp, err := writeTempFile(*path, data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	config, err := vanitycal.LoadConfig(tmp, vanitycal.LoadOptions{})
+	if err == nil {
+		err = vanitycal.ValidateConfig(config)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Fprintf(os.Stderr, "%s: version %d: %v\n", *path, *to, strings.ReplaceAll(err.Error(), tmp, *path))
+		return exitValidationError
+	}
+	// the rollback is a change too, it can be rolled back.
+	version, err := saveSnapshot(*path, data)
+	if err == nil {
+		err = appendAudit(*path, auditEntry{Calendar: calendarName(*path), Actor: localActor(), Action: auditRollback, Detail: fmt.Sprintf("to version %d", *to), Version: version})
+	}
+	if err == nil {
+		err = os.Rename(tmp, *path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Fprintln(os.Stderr, err)
+		return exitOutputError
+	}
+	fmt.Printf("%s: restored version %d as version %d\n", *path, *to, version)
+	return exitOK
+}
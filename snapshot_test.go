@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEventsAPISnapshotsAndRollback(t *testing.T) {
+	t.Setenv(apiTokenEnv, "s3cr3t")
+	path := filepath.Join(t.TempDir(), "team.toml")
+	original := "title = \"Team\"\n"
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	api := &eventsAPI{configs: &configFlags{paths: configPaths{path}}}
+	for _, title := range []string{"First", "Second"} {
+		rec := httptest.NewRecorder()
+		body := `{"title": "` + title + `", "date": "2020-01-02"}`
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/calendars/team/events", strings.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+		}
+	}
+
+	versions, err := snapshotVersions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("versions = %v, want %v", versions, want)
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := os.ReadFile(snapshotPath(path, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(latest) != string(current) {
+		t.Errorf("latest version = %q, want the current config %q", latest, current)
+	}
+
+	if code := runRollback([]string{"-config", path, "-to", strconv.Itoa(1)}); code != exitOK {
+		t.Fatalf("rollback exited with %d", code)
+	}
+	restored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != original {
+		t.Errorf("config = %q, want %q", restored, original)
+	}
+	if versions, _ := snapshotVersions(path); len(versions) != 4 {
+		t.Errorf("versions = %v, want the rollback kept as version 4", versions)
+	}
+}
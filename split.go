@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+type splitIndexEntry struct {
+	Year      int    `json:"year,omitempty"`
+	Recurring bool   `json:"recurring,omitempty"`
+	File      string `json:"file"`
+	Events    int    `json:"events"`
+}
+
+// writeSplitByYear writes one calendar per year in dir, plus an index.json
+// listing them. Recurring events span years: they go, with the extra_events
+// sidecar, to a vanitycal-recurring calendar of their own.
+func writeSplitByYear(dir, format string, config vanitycal.Config, events []vanitycal.GeneratedEvent, encode func([]byte) []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	write := func(name string, config vanitycal.Config, events []vanitycal.GeneratedEvent) error {
+		var buf bytes.Buffer
+		if err := renderers[format](config, events, &buf); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, name), encode(buf.Bytes()), 0o644)
+	}
+
+	byYear := map[int][]vanitycal.GeneratedEvent{}
+	recurring := []vanitycal.GeneratedEvent{}
+	for _, event := range events {
+		if event.RRule != "" || len(event.RDates) > 0 {
+			recurring = append(recurring, event)
+			continue
+		}
+		byYear[event.Date.Year()] = append(byYear[event.Date.Year()], event)
+	}
+	years := make([]int, 0, len(byYear))
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	index := []splitIndexEntry{}
+	if len(recurring) > 0 || (format == "ics" && config.ExtraEvents != "") {
+		name := "vanitycal-recurring." + format
+		if err := write(name, config, recurring); err != nil {
+			return err
+		}
+		index = append(index, splitIndexEntry{Recurring: true, File: name, Events: len(recurring)})
+	}
+	// the extra_events sidecar is only written once, with the recurring events.
+	yearConfig := config
+	yearConfig.ExtraEvents = ""
+	for _, year := range years {
+		name := fmt.Sprintf("vanitycal-%d.%s", year, format)
+		if err := write(name, yearConfig, byYear[year]); err != nil {
+			return err
+		}
+		index = append(index, splitIndexEntry{Year: year, File: name, Events: len(byYear[year])})
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.json"), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// generationStats summarizes a generation, see -stats.
+type generationStats struct {
+	SourceEvents int            `json:"source_events"`
+	Aggregates   int            `json:"aggregates"`
+	Generated    int            `json:"generated"`
+	ByKind       map[string]int `json:"by_kind"`
+	Earliest     string         `json:"earliest,omitempty"`
+	Latest       string         `json:"latest,omitempty"`
+	ByEvent      map[string]int `json:"by_event"`
+}
+
+func computeStats(config vanitycal.Config, events []vanitycal.GeneratedEvent) generationStats {
+	stats := generationStats{
+		SourceEvents: len(config.Events),
+		Aggregates:   len(config.Aggregates),
+		Generated:    len(events),
+		ByKind:       map[string]int{},
+		ByEvent:      map[string]int{},
+	}
+	today := config.Today()
+	for i, event := range events {
+		stats.ByKind[event.Kind]++
+		stats.ByEvent[event.Title]++
+		day := firstOccurrence(event, today).Format("2006-01-02")
+		if i == 0 || day < stats.Earliest {
+			stats.Earliest = day
+		}
+		if day > stats.Latest {
+			stats.Latest = day
+		}
+	}
+	return stats
+}
+
+// firstOccurrence returns the date of the first occurrence of event. Events
+// without a year start in an arbitrary anchor year: their first real
+// occurrence is the next one from today.
+func firstOccurrence(event vanitycal.GeneratedEvent, today time.Time) time.Time {
+	if event.Kind != vanitycal.KindRecurring || event.RRule == "" {
+		return event.Date
+	}
+	// every yearly rule, even a February 29 one, occurs within 8 years.
+	occurrences := vanitycal.ExpandRecurring([]vanitycal.GeneratedEvent{event}, today, today.AddDate(8, 0, 0))
+	if len(occurrences) == 0 {
+		return event.Date
+	}
+	return occurrences[0].Date
+}
+
+// writeStats writes the stats as 'text' or 'json'.
+func writeStats(w io.Writer, stats generationStats, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "source events\t%d\n", stats.SourceEvents)
+	fmt.Fprintf(tw, "aggregates\t%d\n", stats.Aggregates)
+	fmt.Fprintf(tw, "generated\t%d\n", stats.Generated)
+	if stats.Generated > 0 {
+		fmt.Fprintf(tw, "date range\t%s to %s\n", stats.Earliest, stats.Latest)
+	}
+	for _, kind := range sortedKeys(stats.ByKind) {
+		fmt.Fprintf(tw, "  %s\t%d\n", kind, stats.ByKind[kind])
+	}
+	fmt.Fprintln(tw, "per event")
+	for _, title := range sortedKeys(stats.ByEvent) {
+		fmt.Fprintf(tw, "  %s\t%d\n", title, stats.ByEvent[title])
+	}
+	return tw.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
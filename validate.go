@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// runValidate implements `vanitycal validate`: it checks the config without
+// writing a calendar, reporting hard errors and lint-level warnings.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var configs configFlags
+	configs.register(fs)
+	warningsAsErrors := fs.Bool("warnings-as-errors", false, "Exit with the validation error code when there are warnings")
+	_ = fs.Parse(args)
+
+	config, failures, err := configs.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	warnings := []string{}
+	for _, failure := range failures {
+		warnings = append(warnings, failure.Error())
+	}
+
+	if err := vanitycal.ValidateConfig(config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitValidationError
+	}
+	warnings = append(warnings, vanitycal.LintConfig(config, config.Today())...)
+	events, err := vanitycal.GenerateEvents(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitValidationError
+	}
+	warnings = append(warnings, vanitycal.LintEvents(events)...)
+
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	switch {
+	case len(warnings) == 0:
+		fmt.Printf("OK: %d events, %d generated milestones\n", len(config.Events), len(events))
+		return exitOK
+	case *warningsAsErrors:
+		return exitValidationError
+	default:
+		return exitGenerationWarning
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"moul.io/vanitycal/pkg/vanitycal"
+)
+
+// apiEvent is the JSON body of POST /api/calendars/<name>/events.
+type apiEvent struct {
+	Title       string   `json:"title"`
+	Date        string   `json:"date,omitempty"`
+	MonthDay    string   `json:"month_day,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// maxAPIEventSize bounds the body of API requests.
+const maxAPIEventSize = 64 << 10
+
+// eventsAPI lets other systems (forms, HR tools...) push events into the
+// served configs: POST /api/calendars/<name>/events appends the event to the
+// config file named <name>.toml, keeping the previous versions (see
+// `vanitycal rollback`). The calendar is regenerated on the next request,
+// like after any edit.
+type eventsAPI struct {
+	configs *configFlags
+}
+
+// configFilesMu keeps the served calendar from being loaded while the
+// events API replaces a config file.
+var configFilesMu sync.RWMutex
+
+// apiTokenEnv holds the bearer token expected by the events API, so it
+// doesn't show up in the process list.
+const apiTokenEnv = "VANITYCAL_API_TOKEN"
+
+// bearerAuth checks the bearer token of requests against the static API
+// token and the OIDC provider, whichever are configured: either one
+// authenticates a request, on every route it guards.
+type bearerAuth struct {
+	token    string
+	verifier *oidcVerifier
+}
+
+// middleware rejects requests without a valid "Authorization: Bearer" token,
+// and passes who they come from to next, see requestIdentity.
+func (a bearerAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vanitycal"`)
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		identity, ok := a.authenticate(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vanitycal", error="invalid_token"`)
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// staticTokenIdentity is who requests authenticated by the static API token
+// come from, which doesn't tell people apart.
+const staticTokenIdentity = "api-token"
+
+// authenticate returns who token was issued to, reporting false when it is
+// neither the static token nor a valid OIDC token.
+func (a bearerAuth) authenticate(token string) (string, bool) {
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+		return staticTokenIdentity, true
+	}
+	if a.verifier != nil {
+		if identity, err := a.verifier.verify(token, time.Now()); err == nil {
+			return identity, true
+		}
+	}
+	return "", false
+}
+
+type identityKey struct{}
+
+// requestIdentity returns who an authenticated request comes from.
+func requestIdentity(r *http.Request) string {
+	if identity, ok := r.Context().Value(identityKey{}).(string); ok && identity != "" {
+		return identity
+	}
+	return "unknown"
+}
+
+func (a *eventsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/calendars/"), "/events")
+	if !found || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path, found := a.configPath(name)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	if err := checkEditableConfig(path); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	var event apiEvent
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIEventSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&event); err != nil {
+		http.Error(w, fmt.Sprintf("invalid event: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := event.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// configs expand ${VAR} when loaded: keep API text from reading the
+	// server's environment into the served calendar.
+	tags := make([]string, len(event.Tags))
+	for i, tag := range event.Tags {
+		tags[i] = vanitycal.EscapeEnv(tag)
+	}
+	configFilesMu.Lock()
+	defer configFilesMu.Unlock()
+	data, err := appendedEvent(path, addedEvent{
+		Title:       vanitycal.EscapeEnv(event.Title),
+		Date:        event.Date,
+		MonthDay:    event.MonthDay,
+		Description: vanitycal.EscapeEnv(event.Description),
+		Tags:        tags,
+	})
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "can't save the event", http.StatusInternalServerError)
+		return
+	}
+	// keep the served calendar valid: only replace the config once the
+	// edited copy validates.
+	tmp, err := writeTempFile(path, data)
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "can't save the event", http.StatusInternalServerError)
+		return
+	}
+	if err := a.validateEdit(path, tmp); err != nil {
+		os.Remove(tmp)
+		http.Error(w, fmt.Sprintf("invalid event: %v", strings.ReplaceAll(err.Error(), tmp, path)), http.StatusBadRequest)
+		return
+	}
+	// keep every version and who made it, see `vanitycal rollback` and
+	// `vanitycal audit`.
+	identity := requestIdentity(r)
+	version, err := saveSnapshot(path, data)
+	if err == nil {
+		err = appendAudit(path, auditEntry{Calendar: name, Actor: identity, Action: auditAddEvent, Detail: event.Title, Version: version})
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		log.Print(err)
+		http.Error(w, "can't save the event", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("%s: %s added %q through the API, version %d", path, identity, event.Title, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(event)
+}
+
+// configPath returns the served config named name, which may not be
+// editable, see checkEditableConfig.
+func (a *eventsAPI) configPath(name string) (string, bool) {
+	for _, path := range a.configs.paths {
+		if calendarName(path) == name {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// validateEdit validates the served configs with tmp in place of the
+// config at path. The caller holds configFilesMu.
+func (a *eventsAPI) validateEdit(path, tmp string) error {
+	edited := &configFlags{opts: a.configs.opts, now: a.configs.now}
+	for _, p := range a.configs.paths {
+		if p == path {
+			p = tmp
+		}
+		edited.paths = append(edited.paths, p)
+	}
+	config, _, err := edited.load()
+	if err != nil {
+		return err
+	}
+	return vanitycal.ValidateConfig(config)
+}
+
+func (e apiEvent) validate() error {
+	switch {
+	case e.Title == "":
+		return fmt.Errorf("title is required")
+	case (e.Date == "") == (e.MonthDay == ""):
+		return fmt.Errorf("expected either date or month_day")
+	case e.Date != "":
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", e.Date)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEventsAPIEscapesEnv(t *testing.T) {
+	t.Setenv("SECRET_DB_PASS", "hunter2")
+	t.Setenv(apiTokenEnv, "s3cr3t")
+	path := filepath.Join(t.TempDir(), "team.toml")
+	if err := os.WriteFile(path, []byte("title = \"Team\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	api := &eventsAPI{configs: &configFlags{paths: configPaths{path}}}
+
+	body := `{"title": "${SECRET_DB_PASS} ${VANITYCAL_API_TOKEN}", "date": "2020-01-02", "tags": ["${SECRET_DB_PASS}"]}`
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/calendars/team/events", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	config, _, err := api.configs.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(config.Events))
+	}
+	event := config.Events[0]
+	if want := "${SECRET_DB_PASS} ${VANITYCAL_API_TOKEN}"; event.Title != want {
+		t.Errorf("title = %q, want %q", event.Title, want)
+	}
+	if len(event.Tags) != 1 || event.Tags[0] != "${SECRET_DB_PASS}" {
+		t.Errorf("tags = %q, want [${SECRET_DB_PASS}]", event.Tags)
+	}
+}
+
+func TestEventsAPIRejectsNonFileConfigs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "team"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(dir, "team"),
+		"https://example.com/team.toml",
+		filepath.Join(dir, "team.yaml"),
+	} {
+		api := &eventsAPI{configs: &configFlags{paths: configPaths{path}}}
+		body := `{"title": "Launch", "date": "2020-01-02"}`
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/calendars/team/events", strings.NewReader(body)))
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusConflict)
+		}
+	}
+}
+
+func TestEventsAPIKeepsInvalidEditsOut(t *testing.T) {
+	t.Setenv(apiTokenEnv, "s3cr3t")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "team.toml")
+	original := "# team dates\ntitle = \"Team\"\n"
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	api := &eventsAPI{configs: &configFlags{paths: configPaths{path}}}
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/calendars/team/events", strings.NewReader(`{"title": "Bad", "month_day": "13-45"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), ".team.") {
+		t.Errorf("error mentions the temporary file: %s", rec.Body)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != original {
+		t.Errorf("config = %q, want it untouched", data)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/calendars/team/events", strings.NewReader(`{"title": "Good", "date": "2020-01-02"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "team.toml" && entry.Name() != snapshotsDir && entry.Name() != auditLog {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, want 0600", info.Mode().Perm())
+	}
+}